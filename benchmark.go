@@ -0,0 +1,191 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/golang/geo/r3"
+)
+
+// handleBenchmark runs repeated pick-and-place cycles on the same object and summarizes
+// the offsets, success rate and step timings, so calibrations can be compared by numbers
+// rather than by a single pick.
+func (s *handEyeTest) handleBenchmark(ctx context.Context, cycles, objectIndex int, sel selection) (map[string]interface{}, error) {
+	defer func() {
+		s.mu.Lock()
+		s.currentStatus = "idle"
+		s.mu.Unlock()
+	}()
+
+	detectionFrame := s.cfg.detectionFrame()
+	var worldOffset, approachOffset, placeOffset, cycleDuration scalarSeries
+	var worldOffsets []r3.Vector
+	stepDurations := map[string]scalarSeries{}
+	rows := make([]interface{}, 0, cycles)
+	completed, successes := 0, 0
+	// Where the object was last seen in world frame, to follow it between cycles.
+	var lastPosition *r3.Vector
+	stopReason := ""
+
+	for i := 0; i < cycles; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s.reportProgress(ctx, fmt.Sprintf("cycle_%d", i+1))
+		s.logger.Infof("Benchmark cycle %d/%d", i+1, cycles)
+		started := time.Now()
+		row := map[string]interface{}{"cycle": i + 1}
+		rows = append(rows, row)
+
+		s.mu.Lock()
+		s.currentStatus = "detecting"
+		s.mu.Unlock()
+		objects, _, err := s.detectSelected(ctx, sel)
+		if err != nil {
+			row["error"] = fmt.Sprintf("detection failed: %v", err)
+			continue
+		}
+		obj, err := s.benchmarkTarget(ctx, objects, objectIndex, lastPosition, detectionFrame)
+		if err != nil {
+			row["error"] = err.Error()
+			continue
+		}
+
+		result, err := s.executePick(ctx, obj, pickOptions{Place: true})
+		if err != nil {
+			row["error"] = err.Error()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		s.recordHistory("benchmark", result.toMap())
+		completed++
+		duration := time.Since(started).Seconds()
+		cycleDuration = append(cycleDuration, duration)
+
+		row["success"] = result.Success
+		row["duration_s"] = duration
+		row["step_durations_ms"] = result.StepDurationsMs
+		if result.Success {
+			successes++
+		} else {
+			row["reasons"] = result.Verdict.Reasons
+		}
+		if result.approachMeasured {
+			approachOffset = append(approachOffset, vecNorm(result.ApproachOffsetMm))
+			row["approach_offset_mm"] = vecNorm(result.ApproachOffsetMm)
+		} else if result.redetected {
+			row["redetect_matched"] = false
+		}
+		if result.ObjectPositionWorldFrame != (r3.Vector{}) && result.GripperPositionWorldFrame != (r3.Vector{}) {
+			worldOffset = append(worldOffset, vecNorm(result.WorldFrameOffsetMm))
+			worldOffsets = append(worldOffsets, result.WorldFrameOffsetMm)
+			row["world_frame_offset_mm"] = vecNorm(result.WorldFrameOffsetMm)
+			pos := result.ObjectPositionWorldFrame
+			lastPosition = &pos
+		}
+		for step, ms := range result.StepDurationsMs {
+			stepDurations[step] = append(stepDurations[step], ms)
+		}
+
+		if result.Place != nil {
+			if result.Place.Redetected {
+				placeOffset = append(placeOffset, vecNorm(result.Place.PlaceOffsetMm))
+				row["place_offset_mm"] = vecNorm(result.Place.PlaceOffsetMm)
+				pos := result.Place.PlacedPositionWorldFrame
+				lastPosition = &pos
+			}
+			if result.Place.Error != "" {
+				// The object may still be in the gripper or somewhere unknown, so later
+				// cycles would not measure the same thing.
+				row["error"] = "place failed: " + result.Place.Error
+				stopReason = fmt.Sprintf("place failed in cycle %d", i+1)
+				break
+			}
+		}
+	}
+
+	steps := map[string]interface{}{}
+	for step, series := range stepDurations {
+		steps[step] = series.toMap()
+	}
+	summary := map[string]interface{}{
+		"cycles":                    cycles,
+		"completed":                 completed,
+		"successes":                 successes,
+		"world_frame_offset_mm":     worldOffset.toMap(),
+		"world_frame_offset_vector": computeVectorStats(worldOffsets).toMap(),
+		"approach_offset_mm":        approachOffset.toMap(),
+		"place_offset_mm":           placeOffset.toMap(),
+		"cycle_duration_s":          cycleDuration.toMap(),
+		"step_durations_ms":         steps,
+	}
+	if completed > 0 {
+		summary["success_rate"] = float64(successes) / float64(completed)
+	}
+	if stopReason != "" {
+		summary["stopped_early"] = stopReason
+	}
+	summary["verdict"] = benchmarkVerdict(rows, cycles).toMap()
+	s.logger.Infof("Benchmark complete: %d/%d cycles, %d successes", completed, cycles, successes)
+
+	result := map[string]interface{}{
+		"summary": summary,
+		"cycles":  rows,
+	}
+	s.mu.Lock()
+	s.lastResult = result
+	s.mu.Unlock()
+	return result, nil
+}
+
+// benchmarkVerdict passes a benchmark only if every cycle ran and passed, listing the
+// reasons of each cycle that did not.
+func benchmarkVerdict(rows []interface{}, cycles int) verdict {
+	var reasons []string
+	for _, r := range rows {
+		row := r.(map[string]interface{})
+		if err, ok := row["error"]; ok {
+			reasons = append(reasons, fmt.Sprintf("cycle %v: %v", row["cycle"], err))
+		}
+		cycleReasons, _ := row["reasons"].([]string)
+		for _, reason := range cycleReasons {
+			reasons = append(reasons, fmt.Sprintf("cycle %v: %s", row["cycle"], reason))
+		}
+	}
+	if len(rows) < cycles {
+		reasons = append(reasons, fmt.Sprintf("only %d of %d cycles ran", len(rows), cycles))
+	}
+	return verdict{Pass: len(reasons) == 0, Reasons: reasons}
+}
+
+// benchmarkTarget picks the object for a cycle: by index the first time, then the one
+// closest in world frame to where the object was last seen.
+func (s *handEyeTest) benchmarkTarget(
+	ctx context.Context, objects []DetectedObject, objectIndex int, last *r3.Vector, detectionFrame string,
+) (DetectedObject, error) {
+	if last == nil {
+		if objectIndex >= len(objects) {
+			return DetectedObject{}, fmt.Errorf("object_index %d out of range (detected %d objects)", objectIndex, len(objects))
+		}
+		return objects[objectIndex], nil
+	}
+	best := -1
+	bestDist := math.Inf(1)
+	for i, obj := range objects {
+		worldPos, err := s.toWorldFrame(ctx, obj.Center, detectionFrame)
+		if err != nil {
+			return DetectedObject{}, err
+		}
+		if d := vecNorm(worldPos.Sub(*last)); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	if best < 0 {
+		return DetectedObject{}, fmt.Errorf("target not detected")
+	}
+	return objects[best], nil
+}
@@ -0,0 +1,391 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/rimage/transform"
+)
+
+const (
+	// minSquareSidePx is the smallest checkerboard square, in pixels, that is searched for.
+	minSquareSidePx = 6
+	// cornerMatchFraction is how close, as a fraction of a square's side, the corners of two
+	// black squares must be to count as the same inner corner, and how close an inner
+	// corner must be to where the board's outline puts it.
+	cornerMatchFraction = 0.3
+	// pnpIterations limits the Gauss-Newton steps refining the board pose.
+	pnpIterations = 20
+)
+
+// detectCheckerboard finds the configured checkerboard in the camera's color image and
+// solves its pose from the inner corners and the camera intrinsics (PnP), without using
+// depth. The board's frame is centered on its inner corners, with X along its rows, Y
+// toward its first row and Z out of its face; its first row is the one that starts with
+// a black square. The result is in the camera frame, with at most one board.
+func detectCheckerboard(ctx context.Context, cam camera.Camera, cfg *Config) ([]DetectedObject, error) {
+	props, err := cam.Properties(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get camera properties: %w", err)
+	}
+	if props.IntrinsicParams == nil {
+		return nil, fmt.Errorf("checkerboard detection needs the camera's intrinsic parameters")
+	}
+	img, err := colorImage(ctx, cam)
+	if err != nil {
+		return nil, err
+	}
+	board := &cfg.Checkerboard
+	cols, rows := board.InnerCorners[0], board.InnerCorners[1]
+	corners, ok := findCheckerboard(toGray(img), cols, rows)
+	if !ok {
+		return nil, nil
+	}
+	obj, err := solveBoardPose(corners, cols, rows, board.SquareSizeMm, props.IntrinsicParams)
+	if err != nil {
+		return nil, fmt.Errorf("solving checkerboard pose: %w", err)
+	}
+	return []DetectedObject{obj}, nil
+}
+
+// findCheckerboard returns the board's inner corners in pixels, row by row. The black
+// squares are found as dark quadrilaterals; an inner corner is where two of them meet
+// corner to corner. The corners are put in order by mapping the board's outline onto the
+// grid, in whichever of its four turns has the first square black.
+func findCheckerboard(gray *image.Gray, cols, rows int) ([]r2.Point, bool) {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	// Eroding the dark squares separates the ones that touch at a corner.
+	quads := findQuads(erode(adaptiveThreshold(gray), w, h), w, h, minSquareSidePx)
+
+	type squareCorner struct {
+		p    r2.Point
+		side float64
+		quad int
+	}
+	var ends []squareCorner
+	for qi, q := range quads {
+		side := 0.0
+		for i := range q {
+			side += q[(i+1)%4].Sub(q[i]).Norm() / 4
+		}
+		for _, p := range q {
+			ends = append(ends, squareCorner{p, side, qi})
+		}
+	}
+	used := make([]bool, len(ends))
+	var corners []r2.Point
+	var sides []float64
+	for i := range ends {
+		if used[i] {
+			continue
+		}
+		match := -1
+		for j := i + 1; j < len(ends); j++ {
+			d := ends[i].p.Sub(ends[j].p).Norm()
+			if !used[j] && ends[j].quad != ends[i].quad && d < cornerMatchFraction*math.Min(ends[i].side, ends[j].side) &&
+				(match < 0 || d < ends[i].p.Sub(ends[match].p).Norm()) {
+				match = j
+			}
+		}
+		if match < 0 {
+			continue
+		}
+		used[i], used[match] = true, true
+		side := (ends[i].side + ends[match].side) / 2
+		mid := ends[i].p.Add(ends[match].p).Mul(0.5)
+		corners = append(corners, refineSaddle(gray, mid, max(2, int(side/2))))
+		sides = append(sides, side)
+	}
+	if len(corners) != cols*rows {
+		return nil, false
+	}
+
+	outline, ok := hullQuad(convexHull(corners))
+	if !ok {
+		return nil, false
+	}
+	for turn := 0; turn < 4; turn++ {
+		var q quadCorners
+		for i := range q {
+			q[i] = outline[(i+turn)%4]
+		}
+		ordered, ok := assignGrid(q, corners, sides, cols, rows)
+		if !ok {
+			continue
+		}
+		// The square diagonally outside the first corner must be black, and darker than
+		// its white neighbor along the first row.
+		first, okFirst := grayAt(gray, squareToQuad(q, -0.5/float64(cols-1), -0.5/float64(rows-1)))
+		next, okNext := grayAt(gray, squareToQuad(q, 0.5/float64(cols-1), -0.5/float64(rows-1)))
+		if okFirst && okNext && first < next {
+			return ordered, true
+		}
+	}
+	return nil, false
+}
+
+// assignGrid puts the corners in row order, given the board outline's corners starting at
+// the first inner corner: each grid position is projected through the outline and must
+// have a distinct corner close to it.
+func assignGrid(outline quadCorners, corners []r2.Point, sides []float64, cols, rows int) ([]r2.Point, bool) {
+	ordered := make([]r2.Point, 0, cols*rows)
+	taken := make([]bool, len(corners))
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			want := squareToQuad(outline, float64(c)/float64(cols-1), float64(r)/float64(rows-1))
+			best := -1
+			for i, p := range corners {
+				if !taken[i] && p.Sub(want).Norm() < cornerMatchFraction*sides[i] &&
+					(best < 0 || p.Sub(want).Norm() < corners[best].Sub(want).Norm()) {
+					best = i
+				}
+			}
+			if best < 0 {
+				return nil, false
+			}
+			taken[best] = true
+			ordered = append(ordered, corners[best])
+		}
+	}
+	return ordered, true
+}
+
+// refineSaddle moves a checkerboard corner to sub-pixel precision: the point every image
+// gradient in the window around it is perpendicular to (OpenCV's cornerSubPix criterion).
+func refineSaddle(gray *image.Gray, p r2.Point, radius int) r2.Point {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	at := func(x, y int) float64 { return float64(gray.Pix[y*gray.Stride+x]) }
+	for iter := 0; iter < 10; iter++ {
+		cx, cy := int(math.Round(p.X)), int(math.Round(p.Y))
+		if cx-radius < 1 || cy-radius < 1 || cx+radius >= w-1 || cy+radius >= h-1 {
+			return p
+		}
+		var a11, a12, a22, b1, b2 float64
+		for y := cy - radius; y <= cy+radius; y++ {
+			for x := cx - radius; x <= cx+radius; x++ {
+				gx := (at(x+1, y) - at(x-1, y)) / 2
+				gy := (at(x, y+1) - at(x, y-1)) / 2
+				a11 += gx * gx
+				a12 += gx * gy
+				a22 += gy * gy
+				b1 += gx*gx*float64(x) + gx*gy*float64(y)
+				b2 += gx*gy*float64(x) + gy*gy*float64(y)
+			}
+		}
+		det := a11*a22 - a12*a12
+		if math.Abs(det) < 1e-9 {
+			return p
+		}
+		next := r2.Point{X: (a22*b1 - a12*b2) / det, Y: (a11*b2 - a12*b1) / det}
+		if next.Sub(p).Norm() > float64(radius) {
+			return p
+		}
+		done := next.Sub(p).Norm() < 0.01
+		p = next
+		if done {
+			break
+		}
+	}
+	return p
+}
+
+// mat3 is a 3x3 rotation matrix acting on column vectors; its columns are the rotated
+// frame's axes.
+type mat3 [3][3]float64
+
+func (m mat3) apply(v r3.Vector) r3.Vector {
+	return r3.Vector{
+		X: m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		Y: m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		Z: m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+func (m mat3) mul(o mat3) mat3 {
+	var out mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				out[i][j] += m[i][k] * o[k][j]
+			}
+		}
+	}
+	return out
+}
+
+func (m mat3) col(j int) r3.Vector {
+	return r3.Vector{X: m[0][j], Y: m[1][j], Z: m[2][j]}
+}
+
+// rodrigues returns the rotation by |v| radians about v.
+func rodrigues(v r3.Vector) mat3 {
+	theta := v.Norm()
+	if theta < 1e-12 {
+		return mat3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	}
+	k := v.Mul(1 / theta)
+	c, s := math.Cos(theta), math.Sin(theta)
+	kx := mat3{{0, -k.Z, k.Y}, {k.Z, 0, -k.X}, {-k.Y, k.X, 0}}
+	kk := kx.mul(kx)
+	var out mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			out[i][j] = s*kx[i][j] + (1-c)*kk[i][j]
+		}
+		out[i][i]++
+	}
+	return out
+}
+
+// solveBoardPose solves for the board's pose in the camera frame from its corners in
+// pixels. A homography from the board plane gives the starting pose, which Gauss-Newton
+// then refines to minimize the reprojection error.
+func solveBoardPose(
+	corners []r2.Point, cols, rows int, squareMm float64, intrinsics *transform.PinholeCameraIntrinsics,
+) (DetectedObject, error) {
+	model := make([]r3.Vector, len(corners))
+	norm := make([]r2.Point, len(corners))
+	for i, p := range corners {
+		c, r := i%cols, i/cols
+		model[i] = r3.Vector{X: (float64(c) - float64(cols-1)/2) * squareMm, Y: (float64(rows-1)/2 - float64(r)) * squareMm}
+		norm[i] = r2.Point{X: (p.X - intrinsics.Ppx) / intrinsics.Fx, Y: (p.Y - intrinsics.Ppy) / intrinsics.Fy}
+	}
+
+	rot, t, err := homographyPose(model, norm)
+	if err != nil {
+		return DetectedObject{}, err
+	}
+
+	residuals := func(rot mat3, t r3.Vector) []float64 {
+		out := make([]float64, 0, 2*len(model))
+		for i, m := range model {
+			p := rot.apply(m).Add(t)
+			out = append(out, (p.X/p.Z-norm[i].X)*intrinsics.Fx, (p.Y/p.Z-norm[i].Y)*intrinsics.Fy)
+		}
+		return out
+	}
+	// The six parameters perturb the rotation (as a rotation vector) and the translation.
+	perturb := func(rot mat3, t r3.Vector, d [6]float64) (mat3, r3.Vector) {
+		return rodrigues(r3.Vector{X: d[0], Y: d[1], Z: d[2]}).mul(rot), t.Add(r3.Vector{X: d[3], Y: d[4], Z: d[5]})
+	}
+	for iter := 0; iter < pnpIterations; iter++ {
+		res := residuals(rot, t)
+		jac := mat.NewDense(len(res), 6, nil)
+		for k := 0; k < 6; k++ {
+			var d [6]float64
+			d[k] = 1e-6
+			if k >= 3 {
+				d[k] = 1e-3
+			}
+			shifted := residuals(perturb(rot, t, d))
+			for i := range res {
+				jac.Set(i, k, (shifted[i]-res[i])/d[k])
+			}
+		}
+		var step mat.VecDense
+		if err := step.SolveVec(jac, mat.NewVecDense(len(res), res)); err != nil {
+			break
+		}
+		var d [6]float64
+		for k := range d {
+			d[k] = -step.AtVec(k)
+		}
+		rot, t = perturb(rot, t, d)
+		if math.Abs(d[0])+math.Abs(d[1])+math.Abs(d[2]) < 1e-9 && math.Abs(d[3])+math.Abs(d[4])+math.Abs(d[5]) < 1e-6 {
+			break
+		}
+	}
+
+	sumSq := 0.0
+	for _, r := range residuals(rot, t) {
+		sumSq += r * r
+	}
+	orientation, err := orientationFromAxes(rot.col(0), rot.col(1), rot.col(2))
+	if err != nil {
+		return DetectedObject{}, err
+	}
+	// The printed squares reach one square past the inner corners on every side.
+	halfW, halfH := float64(cols+1)/2*squareMm, float64(rows+1)/2*squareMm
+	var outline []r3.Vector
+	for _, sx := range []float64{-1, 1} {
+		for _, sy := range []float64{-1, 1} {
+			outline = append(outline, rot.apply(r3.Vector{X: sx * halfW, Y: sy * halfH}).Add(t))
+		}
+	}
+	return DetectedObject{
+		Center:              t,
+		PointCount:          len(corners),
+		BoundingBox:         computeBoundingBox(outline),
+		OrientedBox:         OrientedBox{Center: t, Axes: [3]r3.Vector{rot.col(0), rot.col(1), rot.col(2)}, Dims: [3]float64{2 * halfW, 2 * halfH, 0}},
+		Orientation:         orientation,
+		ReprojectionErrorPx: math.Sqrt(sumSq / float64(len(corners))),
+	}, nil
+}
+
+// homographyPose estimates the pose of points on the Z = 0 plane from their normalized
+// image coordinates, by decomposing the plane-to-image homography (solved by DLT).
+func homographyPose(model []r3.Vector, norm []r2.Point) (mat3, r3.Vector, error) {
+	// Scale the model to unit size so the DLT is well conditioned.
+	scale := 0.0
+	for _, m := range model {
+		scale = math.Max(scale, math.Max(math.Abs(m.X), math.Abs(m.Y)))
+	}
+	if scale == 0 || len(model) < 4 {
+		return mat3{}, r3.Vector{}, fmt.Errorf("need at least 4 distinct board points")
+	}
+	a := mat.NewDense(2*len(model), 9, nil)
+	for i, m := range model {
+		x, y := m.X/scale, m.Y/scale
+		u, v := norm[i].X, norm[i].Y
+		a.SetRow(2*i, []float64{x, y, 1, 0, 0, 0, -u * x, -u * y, -u})
+		a.SetRow(2*i+1, []float64{0, 0, 0, x, y, 1, -v * x, -v * y, -v})
+	}
+	var svd mat.SVD
+	if !svd.Factorize(a, mat.SVDFull) {
+		return mat3{}, r3.Vector{}, fmt.Errorf("homography did not converge")
+	}
+	var vt mat.Dense
+	svd.VTo(&vt)
+	h := func(row, col int) float64 { return vt.At(3*row+col, 8) }
+	h1 := r3.Vector{X: h(0, 0), Y: h(1, 0), Z: h(2, 0)}
+	h2 := r3.Vector{X: h(0, 1), Y: h(1, 1), Z: h(2, 1)}
+	h3 := r3.Vector{X: h(0, 2), Y: h(1, 2), Z: h(2, 2)}
+
+	// H is proportional to [scale*r1, scale*r2, t]; the board is in front of the camera.
+	k := (h1.Norm() + h2.Norm()) / 2 / scale
+	if h3.Z < 0 {
+		k = -k
+	}
+	r1, r2 := h1.Mul(1/(k*scale)), h2.Mul(1/(k*scale))
+	t := h3.Mul(1 / k)
+
+	// Snap [r1 r2 r1xr2] to the nearest rotation.
+	approx := mat.NewDense(3, 3, nil)
+	for i, c := range []r3.Vector{r1, r2, r1.Cross(r2)} {
+		approx.Set(0, i, c.X)
+		approx.Set(1, i, c.Y)
+		approx.Set(2, i, c.Z)
+	}
+	var rsvd mat.SVD
+	if !rsvd.Factorize(approx, mat.SVDFull) {
+		return mat3{}, r3.Vector{}, fmt.Errorf("rotation did not converge")
+	}
+	var u, v, r mat.Dense
+	rsvd.UTo(&u)
+	rsvd.VTo(&v)
+	r.Mul(&u, v.T())
+	var rot mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			rot[i][j] = r.At(i, j)
+		}
+	}
+	return rot, t, nil
+}
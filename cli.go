@@ -1,14 +1,21 @@
 package handeyetest
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	"github.com/erh/vmodutils"
+	"go.viam.com/rdk/components/gripper"
 	"go.viam.com/rdk/logging"
+	pc "go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
 	generic "go.viam.com/rdk/services/generic"
 )
 
@@ -36,6 +43,90 @@ func addComponentFlags(fs *flag.FlagSet) (arm, camera, gripper *string) {
 	return
 }
 
+// addGraspFlags adds flags for choosing the grasp rotation about the approach axis.
+func addGraspFlags(fs *flag.FlagSet) (mode *string, theta *float64) {
+	mode = fs.String("grasp-orientation", graspKeepCurrent,
+		"grasp rotation: keep_current, align_to_object (jaws across the object's minor axis), or fixed")
+	theta = fs.Float64("grasp-theta", 0, "grasp rotation about the approach axis in degrees (with --grasp-orientation fixed)")
+	return
+}
+
+// addHistoryFlag adds the flag for recording results to a history file.
+func addHistoryFlag(fs *flag.FlagSet) *string {
+	return fs.String("history-file", "", "append results to this JSON-lines history file")
+}
+
+// addPlaceFlags adds flags for the optional place phase after a pick.
+func addPlaceFlags(fs *flag.FlagSet) (place *bool, dropPose *string) {
+	place = fs.Bool("place", false, "put the object back down after the pick and re-detect where it landed")
+	dropPose = fs.String("drop-pose", "", `world-frame release pose as JSON, e.g. '{"x":400,"y":0,"z":60}' (default: where it was picked)`)
+	return
+}
+
+// placeConfig builds the place config from the --place and --drop-pose flag values.
+func placeConfig(place bool, dropPose string) (PlaceConfig, error) {
+	cfg := PlaceConfig{Enabled: place}
+	if dropPose != "" {
+		cfg.DropPose = &PoseConfig{}
+		if err := json.Unmarshal([]byte(dropPose), cfg.DropPose); err != nil {
+			return cfg, fmt.Errorf("invalid --drop-pose: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// addMountFlags adds flags for how the camera is mounted.
+func addMountFlags(fs *flag.FlagSet) (mount, clearPose *string) {
+	mount = fs.String("camera-mount", cameraEyeInHand, "eye_in_hand (camera on the arm) or eye_to_hand (static camera)")
+	clearPose = fs.String("clear-pose", "", `world-frame pose as JSON to move the gripper to before re-detecting a placed object, out of a static camera's view`)
+	return
+}
+
+// parseClearPose parses the --clear-pose flag value.
+func parseClearPose(clearPose string) (*PoseConfig, error) {
+	if clearPose == "" {
+		return nil, nil
+	}
+	pose := &PoseConfig{}
+	if err := json.Unmarshal([]byte(clearPose), pose); err != nil {
+		return nil, fmt.Errorf("invalid --clear-pose: %w", err)
+	}
+	return pose, nil
+}
+
+// thresholdFlags holds pointers to the pass/fail threshold flag values.
+type thresholdFlags struct {
+	worldOffset    *float64
+	worldOffsetX   *float64
+	worldOffsetY   *float64
+	worldOffsetZ   *float64
+	approachOffset *float64
+	redetectDrift  *float64
+}
+
+// addThresholdFlags adds flags for the accuracy a pick must reach to pass.
+func addThresholdFlags(fs *flag.FlagSet) thresholdFlags {
+	return thresholdFlags{
+		worldOffset:    fs.Float64("max-world-offset", 0, "fail if the world-frame offset exceeds this, after the grasp depth (mm, 0 = no limit)"),
+		worldOffsetX:   fs.Float64("max-world-offset-x", 0, "fail if the world-frame offset along X exceeds this (mm, 0 = no limit)"),
+		worldOffsetY:   fs.Float64("max-world-offset-y", 0, "fail if the world-frame offset along Y exceeds this (mm, 0 = no limit)"),
+		worldOffsetZ:   fs.Float64("max-world-offset-z", 0, "fail if the world-frame offset along Z, after the grasp depth, exceeds this (mm, 0 = no limit)"),
+		approachOffset: fs.Float64("max-approach-offset", 0, "fail if the approach offset exceeds this (mm, 0 = no limit)"),
+		redetectDrift:  fs.Float64("max-redetect-drift", 0, "fail if the placed object is re-detected further than this from its target (mm, 0 = no limit)"),
+	}
+}
+
+func (tf thresholdFlags) toConfig() ThresholdsConfig {
+	return ThresholdsConfig{
+		MaxWorldOffsetMm:    *tf.worldOffset,
+		MaxWorldOffsetXMm:   *tf.worldOffsetX,
+		MaxWorldOffsetYMm:   *tf.worldOffsetY,
+		MaxWorldOffsetZMm:   *tf.worldOffsetZ,
+		MaxApproachOffsetMm: *tf.approachOffset,
+		MaxRedetectDriftMm:  *tf.redetectDrift,
+	}
+}
+
 // segmentationFlags holds pointers to all segmentation-related flag values.
 type segmentationFlags struct {
 	detectionFrame   *string
@@ -47,6 +138,10 @@ type segmentationFlags struct {
 	meanKFiltering   *int
 	maxDepth         *float64
 	maxPointCount    *int
+	roiFrame         *string
+	roiMin           *string
+	roiMax           *string
+	roiPolygon       *string
 }
 
 // addSegmentationFlags adds flags for tuning point cloud segmentation.
@@ -61,10 +156,18 @@ func addSegmentationFlags(fs *flag.FlagSet) segmentationFlags {
 		meanKFiltering:   fs.Int("mean-k", 50, "mean-k for noise filtering"),
 		maxDepth:         fs.Float64("max-depth", 0, "max depth in mm (0 = no limit)"),
 		maxPointCount:    fs.Int("max-pts", 0, "max points per object cluster (0 = no limit)"),
+		roiFrame:         fs.String("roi-frame", roiWorld, "frame of the region of interest: world or camera"),
+		roiMin:           fs.String("roi-min", "", "region of interest box corner as x,y,z in mm (with --roi-max)"),
+		roiMax:           fs.String("roi-max", "", "region of interest box corner as x,y,z in mm (with --roi-min)"),
+		roiPolygon:       fs.String("roi-polygon", "", "region of interest outline as x,y;x,y;x,y in mm, in the --roi-frame XY plane"),
 	}
 }
 
-func (sf segmentationFlags) toConfig() SegmentationConfig {
+func (sf segmentationFlags) toConfig() (SegmentationConfig, error) {
+	roi, err := sf.roi()
+	if err != nil {
+		return SegmentationConfig{}, err
+	}
 	return SegmentationConfig{
 		MinPtsInPlane:      *sf.minPtsInPlane,
 		MaxDistFromPlane:   *sf.maxDistFromPlane,
@@ -75,7 +178,111 @@ func (sf segmentationFlags) toConfig() SegmentationConfig {
 		MeanKFiltering:     *sf.meanKFiltering,
 		MaxDepthMm:         *sf.maxDepth,
 		MaxPointCount:      *sf.maxPointCount,
+		ROI:                roi,
+	}, nil
+}
+
+// roi returns the region of interest from the flags, or nil if none was given.
+func (sf segmentationFlags) roi() (*ROIConfig, error) {
+	if *sf.roiMin == "" && *sf.roiMax == "" && *sf.roiPolygon == "" {
+		return nil, nil
+	}
+	roi := &ROIConfig{Frame: *sf.roiFrame}
+	var err error
+	if roi.MinMm, err = parseFloatList(*sf.roiMin); err != nil {
+		return nil, fmt.Errorf("invalid --roi-min: %w", err)
+	}
+	if roi.MaxMm, err = parseFloatList(*sf.roiMax); err != nil {
+		return nil, fmt.Errorf("invalid --roi-max: %w", err)
+	}
+	if *sf.roiPolygon != "" {
+		for _, vertex := range strings.Split(*sf.roiPolygon, ";") {
+			v, err := parseFloatList(vertex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --roi-polygon: %w", err)
+			}
+			roi.Polygon = append(roi.Polygon, v)
+		}
+	}
+	if err := roi.validate(); err != nil {
+		return nil, err
 	}
+	return roi, nil
+}
+
+// detectorFlags holds pointers to the flags choosing and configuring the detector.
+type detectorFlags struct {
+	detector     *string
+	markerSize   *float64
+	markerIDs    *string
+	innerCorners *string
+	squareSize   *float64
+	frames       *int
+}
+
+// addDetectorFlags adds flags for choosing between segmentation, fiducial markers and a
+// checkerboard.
+func addDetectorFlags(fs *flag.FlagSet) detectorFlags {
+	return detectorFlags{
+		detector:     fs.String("detector", detectorPointCloud, "object detector: pointcloud (segmentation), fiducial (ArUco markers) or checkerboard"),
+		markerSize:   fs.Float64("marker-size", 0, "side of the markers' black square in mm, to reject misdetections (0 = any size)"),
+		markerIDs:    fs.String("marker-ids", "", "comma-separated marker IDs to detect (default: all)"),
+		innerCorners: fs.String("inner-corners", "", "checkerboard inner corners as cols,rows (e.g. 7,6; one odd, one even)"),
+		squareSize:   fs.Float64("square-size", 0, "side of a checkerboard square in mm"),
+		frames:       fs.Int("frames", 1, "frames to detect in and average, to reduce depth noise"),
+	}
+}
+
+func (df detectorFlags) toConfig() (string, FiducialConfig, CheckerboardConfig, error) {
+	if err := validateDetector(*df.detector); err != nil {
+		return "", FiducialConfig{}, CheckerboardConfig{}, err
+	}
+	if *df.frames < 1 {
+		return "", FiducialConfig{}, CheckerboardConfig{}, fmt.Errorf("--frames must be at least 1, got %d", *df.frames)
+	}
+	ids, err := parseFloatList(*df.markerIDs)
+	if err != nil {
+		return "", FiducialConfig{}, CheckerboardConfig{}, fmt.Errorf("invalid --marker-ids: %w", err)
+	}
+	fid := FiducialConfig{MarkerSizeMm: *df.markerSize}
+	for _, id := range ids {
+		fid.IDs = append(fid.IDs, int(id))
+	}
+	counts, err := parseFloatList(*df.innerCorners)
+	if err != nil {
+		return "", FiducialConfig{}, CheckerboardConfig{}, fmt.Errorf("invalid --inner-corners: %w", err)
+	}
+	board := CheckerboardConfig{SquareSizeMm: *df.squareSize}
+	for _, n := range counts {
+		board.InnerCorners = append(board.InnerCorners, int(n))
+	}
+	return *df.detector, fid, board, nil
+}
+
+// selectionFlags holds pointers to the flags choosing how detected objects are ordered.
+type selectionFlags struct {
+	strategy *string
+	point    *string
+}
+
+// addSelectionFlags adds flags for the order of detected objects, which --object indexes.
+func addSelectionFlags(fs *flag.FlagSet) selectionFlags {
+	return selectionFlags{
+		strategy: fs.String("select", selectNearestCamera,
+			"object order: nearest_camera, largest (point count), nearest_point (to --select-point), highest or leftmost"),
+		point: fs.String("select-point", "", "world X,Y in mm for --select nearest_point"),
+	}
+}
+
+func (sf selectionFlags) toConfig() (string, []float64, error) {
+	point, err := parseFloatList(*sf.point)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid --select-point: %w", err)
+	}
+	if err := validateSelection(*sf.strategy, point); err != nil {
+		return "", nil, err
+	}
+	return *sf.strategy, point, nil
 }
 
 func runCLI(subcommand string, args []string) error {
@@ -87,6 +294,7 @@ func runCLI(subcommand string, args []string) error {
 	var armName, cameraName, gripperName *string
 	var cfg Config
 	var cmdMap map[string]interface{}
+	var collectPicks, collectObjectIndex int
 
 	switch subcommand {
 	case "detect":
@@ -95,12 +303,28 @@ func runCLI(subcommand string, args []string) error {
 			fmt.Fprintf(os.Stderr, `Capture a point cloud from the camera and detect objects using plane segmentation
 and clustering. Returns a list of detected objects with their positions and point counts.
 
+With --pcd, runs the same pipeline on a saved point cloud instead (see 'capture');
+no machine connection is needed, which makes it easy to tune segmentation offline.
+
+With --detector fiducial, finds ArUco markers in the color image instead and reports
+each one's ID and orientation as well. With --detector checkerboard, finds one board
+with the given inner corners and square size and solves its pose from the color image
+alone, reporting the reprojection error.
+
+With --frames N, detects in N frames, matches each object across them and reports
+its averaged center with the per-axis standard deviation, the depth noise.
+
 Usage:
   hand-eye-test detect --host <address> [flags]
+  hand-eye-test detect --pcd <file> [flags]
 
 Example:
   hand-eye-test detect --host my-robot.viam.cloud
   hand-eye-test detect --host my-robot.viam.cloud --camera wrist-cam --min-pts 200
+  hand-eye-test detect --pcd scan.pcd --clustering-radius 8 --max-dist-from-plane 3
+  hand-eye-test detect --host my-robot.viam.cloud --frames 10
+  hand-eye-test detect --host my-robot.viam.cloud --detector fiducial --marker-size 30
+  hand-eye-test detect --host my-robot.viam.cloud --detector checkerboard --inner-corners 7,6 --square-size 20
 
 Flags:
 `)
@@ -109,16 +333,77 @@ Flags:
 		host, debug = addConnectionFlags(fs)
 		armName, cameraName, gripperName = addComponentFlags(fs)
 		seg := addSegmentationFlags(fs)
+		det := addDetectorFlags(fs)
+		selFlags := addSelectionFlags(fs)
+		pcdFile := fs.String("pcd", "", "run detection on this PCD file instead of the live camera (no --host needed)")
 		if err := fs.Parse(args); err != nil {
 			return err
 		}
+		detector, fiducial, board, err := det.toConfig()
+		if err != nil {
+			return err
+		}
+		segmentation, err := seg.toConfig()
+		if err != nil {
+			return err
+		}
+		selectionStrategy, selectionPoint, err := selFlags.toConfig()
+		if err != nil {
+			return err
+		}
 		cfg = Config{
 			Arm: *armName, Camera: *cameraName, Gripper: *gripperName,
 			DetectionFrame: *seg.detectionFrame,
-			Segmentation:   seg.toConfig(),
+			Segmentation:   segmentation,
+			Detector:       detector,
+			Fiducial:       fiducial,
+			Checkerboard:   board,
+			Frames:         *det.frames,
+			Selection:      selectionStrategy,
+			SelectionPoint: selectionPoint,
+		}
+		if *pcdFile != "" {
+			if detector != detectorPointCloud {
+				return fmt.Errorf("--pcd needs the pointcloud detector; %s detection reads the camera's color image", detector)
+			}
+			if *det.frames > 1 {
+				return fmt.Errorf("--frames needs a live camera; a PCD file is one frame")
+			}
+			if segmentation.ROI != nil && segmentation.ROI.frame() == roiWorld {
+				return fmt.Errorf("a PCD file has no world pose; use --roi-frame camera")
+			}
+			return detectFromFile(ctx, *pcdFile, &cfg, logger)
 		}
 		cmdMap = map[string]interface{}{"command": "detect"}
 
+	case "capture":
+		fs := flag.NewFlagSet("capture", flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, `Save the camera's current point cloud to a PCD file, for offline tuning with
+'detect --pcd' or as a regression fixture.
+
+Usage:
+  hand-eye-test capture --host <address> --out <file> [flags]
+
+Example:
+  hand-eye-test capture --host my-robot.viam.cloud --out scan.pcd
+  hand-eye-test capture --host my-robot.viam.cloud --camera wrist-cam --out fixtures/two-boxes.pcd
+
+Flags:
+`)
+			fs.PrintDefaults()
+		}
+		host, debug = addConnectionFlags(fs)
+		armName, cameraName, gripperName = addComponentFlags(fs)
+		out := fs.String("out", "scan.pcd", "path of the PCD file to write")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		cfg = Config{
+			Arm: *armName, Camera: *cameraName, Gripper: *gripperName,
+		}
+		cmdMap = map[string]interface{}{"command": "capture", "path": *out}
+
 	case "pick":
 		fs := flag.NewFlagSet("pick", flag.ExitOnError)
 		fs.Usage = func() {
@@ -132,7 +417,10 @@ Flags:
   7. Lift
   8. Verify gripper is holding something
 
-Reports calibration accuracy as approach offset and world-frame offset in mm.
+Reports calibration accuracy as approach offset and world-frame offset in mm, and a
+verdict: the pick passes if the object was held and every --max-* limit was met.
+The command exits non-zero when the verdict fails. With --place the object is put
+back down afterwards; with --dry-run the moves are only planned and nothing moves.
 
 Usage:
   hand-eye-test pick --host <address> [flags]
@@ -140,6 +428,278 @@ Usage:
 Example:
   hand-eye-test pick --host my-robot.viam.cloud
   hand-eye-test pick --host my-robot.viam.cloud --object 1 --approach-offset 80
+  hand-eye-test pick --host my-robot.viam.cloud --place
+  hand-eye-test pick --host my-robot.viam.cloud --dry-run
+  hand-eye-test pick --host my-robot.viam.cloud --place --max-world-offset 5 --max-redetect-drift 3
+
+Flags:
+`)
+			fs.PrintDefaults()
+		}
+		host, debug = addConnectionFlags(fs)
+		armName, cameraName, gripperName = addComponentFlags(fs)
+		seg := addSegmentationFlags(fs)
+		det := addDetectorFlags(fs)
+		selFlags := addSelectionFlags(fs)
+		objectIndex := fs.Int("object", 0, "index of detected object to pick, in --select order")
+		approachOffset := fs.Float64("approach-offset", 100, "mm above object for approach pose")
+		graspOffset := fs.Float64("grasp-offset", 0, "mm adjustment for grasp height above the detected point (negative = deeper)")
+		liftHeight := fs.Float64("lift-height", 50, "mm to lift after grasping")
+		graspOrientation, graspTheta := addGraspFlags(fs)
+		cameraMount, clearPose := addMountFlags(fs)
+		place, dropPose := addPlaceFlags(fs)
+		dryRun := fs.Bool("dry-run", false, "plan the approach, grasp and lift without moving the arm or gripper")
+		thresholds := addThresholdFlags(fs)
+		historyFile := addHistoryFlag(fs)
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		detector, fiducial, board, err := det.toConfig()
+		if err != nil {
+			return err
+		}
+		segmentation, err := seg.toConfig()
+		if err != nil {
+			return err
+		}
+		selectionStrategy, selectionPoint, err := selFlags.toConfig()
+		if err != nil {
+			return err
+		}
+		if err := validateGraspOrientation(*graspOrientation); err != nil {
+			return err
+		}
+		if err := validateCameraMount(*cameraMount); err != nil {
+			return err
+		}
+		placeCfg, err := placeConfig(*place, *dropPose)
+		if err != nil {
+			return err
+		}
+		clearPoseCfg, err := parseClearPose(*clearPose)
+		if err != nil {
+			return err
+		}
+		cfg = Config{
+			Arm: *armName, Camera: *cameraName, Gripper: *gripperName,
+			DetectionFrame:     *seg.detectionFrame,
+			ApproachOffsetMm:   *approachOffset,
+			GraspDepthOffsetMm: *graspOffset,
+			LiftHeightMm:       *liftHeight,
+			CameraMount:        *cameraMount,
+			GraspOrientation:   *graspOrientation,
+			GraspThetaDeg:      *graspTheta,
+			Segmentation:       segmentation,
+			Detector:           detector,
+			Fiducial:           fiducial,
+			Checkerboard:       board,
+			Frames:             *det.frames,
+			Selection:          selectionStrategy,
+			SelectionPoint:     selectionPoint,
+			Place:              placeCfg,
+			ClearPose:          clearPoseCfg,
+			Thresholds:         thresholds.toConfig(),
+			HistoryFile:        *historyFile,
+		}
+		cmdMap = map[string]interface{}{"command": "pick", "object_index": float64(*objectIndex), "dry_run": *dryRun}
+
+	case "benchmark":
+		fs := flag.NewFlagSet("benchmark", flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, `Run repeated pick-and-place cycles on the same object and summarize repeatability.
+Each cycle detects the object, picks it, places it back (where it was picked from,
+or at --drop-pose) and re-detects it. After the first cycle the object is followed
+by its last world position rather than by index.
+
+Prints one JSON summary (mean, std-dev and p50/p90/p95 of the world-frame, approach
+and place offsets, success rate, per-step timings) plus a row per cycle. The summary
+verdict passes only if every cycle ran and passed the --max-* limits; the command
+exits non-zero when it fails.
+
+Usage:
+  hand-eye-test benchmark --host <address> [flags]
+
+Example:
+  hand-eye-test benchmark --host my-robot.viam.cloud --cycles 20
+  hand-eye-test benchmark --host my-robot.viam.cloud --cycles 10 --drop-pose '{"x":400,"y":0,"z":60}'
+
+Flags:
+`)
+			fs.PrintDefaults()
+		}
+		host, debug = addConnectionFlags(fs)
+		armName, cameraName, gripperName = addComponentFlags(fs)
+		seg := addSegmentationFlags(fs)
+		det := addDetectorFlags(fs)
+		selFlags := addSelectionFlags(fs)
+		objectIndex := fs.Int("object", 0, "index of detected object to pick in the first cycle")
+		cycles := fs.Int("cycles", 10, "number of pick-and-place cycles")
+		approachOffset := fs.Float64("approach-offset", 100, "mm above object for approach pose")
+		graspOffset := fs.Float64("grasp-offset", 0, "mm adjustment for grasp height above the detected point (negative = deeper)")
+		liftHeight := fs.Float64("lift-height", 50, "mm to lift after grasping")
+		graspOrientation, graspTheta := addGraspFlags(fs)
+		cameraMount, clearPose := addMountFlags(fs)
+		dropPose := fs.String("drop-pose", "", `world-frame release pose as JSON (default: where it was picked)`)
+		thresholds := addThresholdFlags(fs)
+		historyFile := addHistoryFlag(fs)
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		detector, fiducial, board, err := det.toConfig()
+		if err != nil {
+			return err
+		}
+		segmentation, err := seg.toConfig()
+		if err != nil {
+			return err
+		}
+		selectionStrategy, selectionPoint, err := selFlags.toConfig()
+		if err != nil {
+			return err
+		}
+		if err := validateGraspOrientation(*graspOrientation); err != nil {
+			return err
+		}
+		if err := validateCameraMount(*cameraMount); err != nil {
+			return err
+		}
+		placeCfg, err := placeConfig(true, *dropPose)
+		if err != nil {
+			return err
+		}
+		clearPoseCfg, err := parseClearPose(*clearPose)
+		if err != nil {
+			return err
+		}
+		cfg = Config{
+			Arm: *armName, Camera: *cameraName, Gripper: *gripperName,
+			DetectionFrame:     *seg.detectionFrame,
+			ApproachOffsetMm:   *approachOffset,
+			GraspDepthOffsetMm: *graspOffset,
+			LiftHeightMm:       *liftHeight,
+			CameraMount:        *cameraMount,
+			GraspOrientation:   *graspOrientation,
+			GraspThetaDeg:      *graspTheta,
+			Segmentation:       segmentation,
+			Detector:           detector,
+			Fiducial:           fiducial,
+			Checkerboard:       board,
+			Frames:             *det.frames,
+			Selection:          selectionStrategy,
+			SelectionPoint:     selectionPoint,
+			Place:              placeCfg,
+			ClearPose:          clearPoseCfg,
+			Thresholds:         thresholds.toConfig(),
+			HistoryFile:        *historyFile,
+		}
+		cmdMap = map[string]interface{}{
+			"command": "benchmark", "cycles": float64(*cycles), "object_index": float64(*objectIndex),
+		}
+
+	case "sweep":
+		fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, `Move the gripper through a set of observation poses around a fixed object, detect
+it from each one, transform every detection to the world frame and report the spread
+(mean, std-dev, max deviation) of its apparent world position. A well-calibrated camera
+produces a tight cluster; rotational mount errors show up as viewpoint-dependent drift.
+
+Poses come from a JSON file (a list of {"x","y","z","o_x","o_y","o_z","theta"} objects
+in the world frame), or are generated as a ring of --count poses at --radius and
+--height around the --target-x/y/z point, all looking at it.
+
+Usage:
+  hand-eye-test sweep --host <address> (--poses <file> | --target-x <mm> --target-y <mm> --target-z <mm>) [flags]
+
+Example:
+  hand-eye-test sweep --host my-robot.viam.cloud --poses sweep-poses.json
+  hand-eye-test sweep --host my-robot.viam.cloud --target-x 450 --target-y 0 --target-z 20 --radius 120 --height 300 --count 8
+
+Flags:
+`)
+			fs.PrintDefaults()
+		}
+		host, debug = addConnectionFlags(fs)
+		armName, cameraName, gripperName = addComponentFlags(fs)
+		seg := addSegmentationFlags(fs)
+		det := addDetectorFlags(fs)
+		selFlags := addSelectionFlags(fs)
+		objectIndex := fs.Int("object", 0, "index of the target object in the first pose's detections")
+		posesFile := fs.String("poses", "", "JSON file with a list of world-frame observation poses")
+		targetX := fs.Float64("target-x", 0, "target X position in world frame (mm)")
+		targetY := fs.Float64("target-y", 0, "target Y position in world frame (mm)")
+		targetZ := fs.Float64("target-z", 0, "target Z position in world frame (mm)")
+		radius := fs.Float64("radius", 100, "horizontal distance of generated poses from the target (mm)")
+		height := fs.Float64("height", 300, "height of generated poses above the target (mm)")
+		count := fs.Int("count", 6, "number of generated poses")
+		historyFile := addHistoryFlag(fs)
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		detector, fiducial, board, err := det.toConfig()
+		if err != nil {
+			return err
+		}
+		segmentation, err := seg.toConfig()
+		if err != nil {
+			return err
+		}
+		selectionStrategy, selectionPoint, err := selFlags.toConfig()
+		if err != nil {
+			return err
+		}
+		cfg = Config{
+			Arm: *armName, Camera: *cameraName, Gripper: *gripperName,
+			DetectionFrame: *seg.detectionFrame,
+			Segmentation:   segmentation,
+			Detector:       detector,
+			Fiducial:       fiducial,
+			Checkerboard:   board,
+			Frames:         *det.frames,
+			Selection:      selectionStrategy,
+			SelectionPoint: selectionPoint,
+			HistoryFile:    *historyFile,
+			Sweep: SweepConfig{
+				Target:   []float64{*targetX, *targetY, *targetZ},
+				RadiusMm: *radius,
+				HeightMm: *height,
+				Count:    *count,
+			},
+		}
+		if *posesFile != "" {
+			data, err := os.ReadFile(*posesFile)
+			if err != nil {
+				return fmt.Errorf("reading poses file: %w", err)
+			}
+			if err := json.Unmarshal(data, &cfg.Sweep.Poses); err != nil {
+				return fmt.Errorf("parsing poses file: %w", err)
+			}
+		}
+		cmdMap = map[string]interface{}{"command": "sweep", "object_index": float64(*objectIndex)}
+
+	case "present":
+		fs := flag.NewFlagSet("present", flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, `Validate a static (eye-to-hand) camera: the arm holds a target and shows it to the
+camera at a set of poses. At each one the target's detected world position is compared
+with where the arm's kinematics put it. The mean offset is the calibration error; the
+spread is what changes with where the target sits in the camera's view.
+
+The target must already be in the gripper (e.g. after 'pick' without --place) and stand
+clear of it, so it is segmented as its own object. --target-offset is the detected point
+on the target (e.g. the center of its top face) in the gripper frame; with the gripper
+pointing down, gripper Z points down too.
+
+Poses come from a JSON file (a list of {"x","y","z","o_x","o_y","o_z","theta"} objects
+in the world frame), or are generated as the --center-x/y/z point plus a ring of --count
+poses at --radius around it, all with the gripper pointing straight down.
+
+Usage:
+  hand-eye-test present --host <address> (--poses <file> | --center-x <mm> --center-y <mm> --center-z <mm>) [flags]
+
+Example:
+  hand-eye-test present --host my-robot.viam.cloud --center-x 450 --center-y 0 --center-z 250 --target-offset 0,0,-15
+  hand-eye-test present --host my-robot.viam.cloud --poses present-poses.json --target-offset 0,0,-40
 
 Flags:
 `)
@@ -148,22 +708,205 @@ Flags:
 		host, debug = addConnectionFlags(fs)
 		armName, cameraName, gripperName = addComponentFlags(fs)
 		seg := addSegmentationFlags(fs)
-		objectIndex := fs.Int("object", 0, "index of detected object to pick (0 = closest/largest)")
+		det := addDetectorFlags(fs)
+		posesFile := fs.String("poses", "", "JSON file with a list of world-frame presentation poses")
+		centerX := fs.Float64("center-x", 0, "center of the generated poses, X in world frame (mm)")
+		centerY := fs.Float64("center-y", 0, "center of the generated poses, Y in world frame (mm)")
+		centerZ := fs.Float64("center-z", 0, "center of the generated poses, Z in world frame (mm)")
+		radius := fs.Float64("radius", 100, "distance of the generated ring of poses from the center (mm)")
+		count := fs.Int("count", 6, "number of poses in the generated ring")
+		targetOffset := fs.String("target-offset", "0,0,0", "detected point on the target in the gripper frame, as x,y,z (mm)")
+		historyFile := addHistoryFlag(fs)
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		detector, fiducial, board, err := det.toConfig()
+		if err != nil {
+			return err
+		}
+		segmentation, err := seg.toConfig()
+		if err != nil {
+			return err
+		}
+		offset, err := parseFloatList(*targetOffset)
+		if err != nil || len(offset) != 3 {
+			return fmt.Errorf("invalid --target-offset %q: need x,y,z in mm", *targetOffset)
+		}
+		cfg = Config{
+			Arm: *armName, Camera: *cameraName, Gripper: *gripperName,
+			CameraMount:    cameraEyeToHand,
+			DetectionFrame: *seg.detectionFrame,
+			Segmentation:   segmentation,
+			Detector:       detector,
+			Fiducial:       fiducial,
+			Checkerboard:   board,
+			Frames:         *det.frames,
+			HistoryFile:    *historyFile,
+			Present: PresentConfig{
+				Center:         []float64{*centerX, *centerY, *centerZ},
+				RadiusMm:       *radius,
+				Count:          *count,
+				TargetOffsetMm: offset,
+			},
+		}
+		if *posesFile != "" {
+			data, err := os.ReadFile(*posesFile)
+			if err != nil {
+				return fmt.Errorf("reading poses file: %w", err)
+			}
+			if err := json.Unmarshal(data, &cfg.Present.Poses); err != nil {
+				return fmt.Errorf("parsing poses file: %w", err)
+			}
+		}
+		cmdMap = map[string]interface{}{"command": "present"}
+
+	case "correct":
+		fs := flag.NewFlagSet("correct", flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, `Run a series of picks, each followed by a place. Re-detecting the placed object pairs
+a camera-frame detection with the world position the gripper put it at; the camera-to-arm
+transform that best explains the pairs is then solved by least squares. Prints a frame
+system "frame" block ready to paste into the camera's config, plus the residual before
+and after correction.
+
+Between picks the CLI waits so the object can be moved to a new position. Vary the
+position (and for rotation, the arm pose) as much as possible between picks.
+
+Usage:
+  hand-eye-test correct --host <address> [flags]
+
+Example:
+  hand-eye-test correct --host my-robot.viam.cloud --picks 6
+  hand-eye-test correct --host my-robot.viam.cloud --picks 3 --translation-only
+
+Flags:
+`)
+			fs.PrintDefaults()
+		}
+		host, debug = addConnectionFlags(fs)
+		armName, cameraName, gripperName = addComponentFlags(fs)
+		seg := addSegmentationFlags(fs)
+		det := addDetectorFlags(fs)
+		selFlags := addSelectionFlags(fs)
+		objectIndex := fs.Int("object", 0, "index of detected object to pick, in --select order")
 		approachOffset := fs.Float64("approach-offset", 100, "mm above object for approach pose")
-		graspOffset := fs.Float64("grasp-offset", 0, "mm adjustment for grasp depth (positive = deeper)")
+		graspOffset := fs.Float64("grasp-offset", 0, "mm adjustment for grasp height above the detected point (negative = deeper)")
 		liftHeight := fs.Float64("lift-height", 50, "mm to lift after grasping")
+		graspOrientation, graspTheta := addGraspFlags(fs)
+		cameraParent := fs.String("camera-parent", "", "frame the camera is mounted to (default: arm name)")
+		picks := fs.Int("picks", 5, "number of picks to collect before solving")
+		translationOnly := fs.Bool("translation-only", false, "only correct the translation, keep the current rotation")
+		historyFile := addHistoryFlag(fs)
 		if err := fs.Parse(args); err != nil {
 			return err
 		}
+		detector, fiducial, board, err := det.toConfig()
+		if err != nil {
+			return err
+		}
+		segmentation, err := seg.toConfig()
+		if err != nil {
+			return err
+		}
+		selectionStrategy, selectionPoint, err := selFlags.toConfig()
+		if err != nil {
+			return err
+		}
+		if err := validateGraspOrientation(*graspOrientation); err != nil {
+			return err
+		}
 		cfg = Config{
 			Arm: *armName, Camera: *cameraName, Gripper: *gripperName,
 			DetectionFrame:     *seg.detectionFrame,
+			CameraParentFrame:  *cameraParent,
 			ApproachOffsetMm:   *approachOffset,
 			GraspDepthOffsetMm: *graspOffset,
 			LiftHeightMm:       *liftHeight,
-			Segmentation:       seg.toConfig(),
+			GraspOrientation:   *graspOrientation,
+			GraspThetaDeg:      *graspTheta,
+			Segmentation:       segmentation,
+			Detector:           detector,
+			Fiducial:           fiducial,
+			Checkerboard:       board,
+			Frames:             *det.frames,
+			Selection:          selectionStrategy,
+			SelectionPoint:     selectionPoint,
+			HistoryFile:        *historyFile,
 		}
-		cmdMap = map[string]interface{}{"command": "pick", "object_index": float64(*objectIndex)}
+		collectPicks, collectObjectIndex = *picks, *objectIndex
+		cmdMap = map[string]interface{}{"command": "solve_correction", "translation_only": *translationOnly}
+
+	case "touch":
+		fs := flag.NewFlagSet("touch", flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, `Detect objects, then touch the center of one object's top surface with the tool tip.
+No gripper is needed, so this validates calibration on arms that carry only a probe.
+The sequence is:
+  1. Move above the target (via motion planning), pointing down the surface normal
+  2. Descend to --standoff mm above the surface (straight line via arm driver)
+  3. Compare the tool tip's world-frame position to the target
+  4. Retract to the approach position
+
+Reports the world-frame error in mm. Give the probe tip as --tool-frame (a frame in
+the frame system) and leave out the gripper with --gripper "".
+
+Usage:
+  hand-eye-test touch --host <address> --tool-frame <frame> [flags]
+
+Example:
+  hand-eye-test touch --host my-robot.viam.cloud --gripper "" --tool-frame probe-tip
+  hand-eye-test touch --host my-robot.viam.cloud --gripper "" --tool-frame probe-tip --standoff 2 --max-world-offset 3
+
+Flags:
+`)
+			fs.PrintDefaults()
+		}
+		host, debug = addConnectionFlags(fs)
+		armName, cameraName, gripperName = addComponentFlags(fs)
+		toolFrame := fs.String("tool-frame", "", "frame moved onto the target, e.g. a probe tip (default: the gripper)")
+		seg := addSegmentationFlags(fs)
+		det := addDetectorFlags(fs)
+		selFlags := addSelectionFlags(fs)
+		objectIndex := fs.Int("object", 0, "index of detected object to touch, in --select order")
+		approachOffset := fs.Float64("approach-offset", 100, "mm above the target for the approach pose")
+		standoff := fs.Float64("standoff", 0, "mm above the surface to stop the tool tip (0 = touch it)")
+		maxWorldOffset := fs.Float64("max-world-offset", 0, "fail if the tool tip misses the target by more than this (mm, 0 = no limit)")
+		historyFile := addHistoryFlag(fs)
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *gripperName == "" && *toolFrame == "" {
+			return fmt.Errorf("--tool-frame is required without a gripper")
+		}
+		detector, fiducial, board, err := det.toConfig()
+		if err != nil {
+			return err
+		}
+		segmentation, err := seg.toConfig()
+		if err != nil {
+			return err
+		}
+		selectionStrategy, selectionPoint, err := selFlags.toConfig()
+		if err != nil {
+			return err
+		}
+		cfg = Config{
+			Arm: *armName, Camera: *cameraName, Gripper: *gripperName,
+			ToolFrame:        *toolFrame,
+			DetectionFrame:   *seg.detectionFrame,
+			ApproachOffsetMm: *approachOffset,
+			TouchStandoffMm:  *standoff,
+			Segmentation:     segmentation,
+			Detector:         detector,
+			Fiducial:         fiducial,
+			Checkerboard:     board,
+			Frames:           *det.frames,
+			Selection:        selectionStrategy,
+			SelectionPoint:   selectionPoint,
+			Thresholds:       ThresholdsConfig{MaxWorldOffsetMm: *maxWorldOffset},
+			HistoryFile:      *historyFile,
+		}
+		cmdMap = map[string]interface{}{"command": "touch", "object_index": float64(*objectIndex)}
 
 	case "move-to":
 		fs := flag.NewFlagSet("move-to", flag.ExitOnError)
@@ -204,6 +947,104 @@ Flags:
 			"step_size": *moveStepSize,
 		}
 
+	case "history":
+		fs := flag.NewFlagSet("history", flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, `Summarize the pick and sweep results recorded in a history file (written by
+'pick', 'sweep' and 'correct' with --history-file, or by the service's history_file
+config). Reports offset statistics overall and per day, plus the world-frame offset
+trend in mm/day, so drift after e.g. bumping the wrist camera is easy to spot.
+Reads the file locally; no machine connection is needed.
+
+Usage:
+  hand-eye-test history --file <path> [flags]
+
+Example:
+  hand-eye-test history --file calibration-history.jsonl
+  hand-eye-test history --file calibration-history.jsonl --since 2025-06-01 --until 2025-06-07 --entries
+
+Flags:
+`)
+			fs.PrintDefaults()
+		}
+		file := fs.String("file", "", "history file to read (required)")
+		since := fs.String("since", "", "only include results at or after this time (RFC3339 or YYYY-MM-DD)")
+		until := fs.String("until", "", "only include results before this time (RFC3339, or YYYY-MM-DD inclusive)")
+		entries := fs.Bool("entries", false, "include the individual results, not just the summary")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *file == "" {
+			return fmt.Errorf("--file is required")
+		}
+		result, err := queryHistory(*file, *since, *until, *entries)
+		if err != nil {
+			return err
+		}
+		return printJSON(result)
+
+	case "sensitivity":
+		fs := flag.NewFlagSet("sensitivity", flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(os.Stderr, `Check how small a calibration error this tool can detect. Runs against a built-in
+simulated cell (no machine connection needed): for each requested error, the camera
+mount the frame system reports is perturbed by a known translation or rotation, then
+the normal pick-and-place (or sweep) sequence runs and what it measured is compared
+with what was injected. A zero-error baseline runs first; an error counts as detected
+when the measurement departs from the baseline by more than --tolerance.
+
+Prints a table to stderr and the full results, including the smallest detectable error
+per kind and axis, as JSON.
+
+Usage:
+  hand-eye-test sensitivity [flags]
+
+Example:
+  hand-eye-test sensitivity
+  hand-eye-test sensitivity --mode sweep --axes x,y --rotations 0.1,0.25,0.5
+  hand-eye-test sensitivity --translations 0.5,1,2 --rotations "" --noise 0.5
+
+Flags:
+`)
+			fs.PrintDefaults()
+		}
+		debug = fs.Bool("debug", false, "enable debug logging")
+		mode := fs.String("mode", sensitivityPick, "measure with pick-and-place cycles (pick) or a sweep around the object (sweep)")
+		translations := fs.String("translations", "1,2,5,10", "comma-separated translation errors to inject (mm)")
+		rotations := fs.String("rotations", "0.25,0.5,1,2", "comma-separated rotation errors to inject (degrees)")
+		axes := fs.String("axes", "x,y,z", "comma-separated camera-frame axes to inject errors along/about")
+		picks := fs.Int("picks", 4, "pick-and-place cycles per injected error (pick mode, at least 3)")
+		sweepPoses := fs.Int("sweep-poses", 8, "observation poses per injected error (sweep mode)")
+		tolerance := fs.Float64("tolerance", 1, "how far a measurement must depart from the baseline to count as detected (mm)")
+		noise := fs.Float64("noise", 0, "standard deviation of simulated depth noise (mm)")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		opts := sensitivityOptions{
+			Mode: *mode, Picks: *picks, SweepPoses: *sweepPoses, ToleranceMm: *tolerance, NoiseMm: *noise,
+		}
+		var err error
+		if opts.TranslationsMm, err = parseFloatList(*translations); err != nil {
+			return fmt.Errorf("invalid --translations: %w", err)
+		}
+		if opts.RotationsDeg, err = parseFloatList(*rotations); err != nil {
+			return fmt.Errorf("invalid --rotations: %w", err)
+		}
+		for _, axis := range strings.Split(*axes, ",") {
+			if axis = strings.TrimSpace(axis); axis != "" {
+				opts.Axes = append(opts.Axes, axis)
+			}
+		}
+		if *debug {
+			logger.SetLevel(logging.DEBUG)
+		}
+		report, err := runSensitivity(ctx, opts, logger)
+		if err != nil {
+			return err
+		}
+		report.writeTable(os.Stderr)
+		return printJSON(report.toMap())
+
 	case "status":
 		fs := flag.NewFlagSet("status", flag.ExitOnError)
 		fs.Usage = func() {
@@ -260,16 +1101,155 @@ Flags:
 	}
 	defer svc.Close(ctx)
 
-	result, err := svc.DoCommand(ctx, cmdMap)
+	if collectPicks > 0 {
+		if err := collectCorrectionPicks(ctx, svc, deps, &cfg, collectPicks, collectObjectIndex); err != nil {
+			return err
+		}
+	}
+
+	result, err := runCommand(ctx, svc, cmdMap)
 	if err != nil {
 		return err
 	}
+	if err := printJSON(result); err != nil {
+		return err
+	}
+	return verdictError(result)
+}
 
+// verdictError turns a failed pick, touch or benchmark verdict into an error, so the CLI exits
+// non-zero and scripts can gate on it.
+func verdictError(result map[string]interface{}) error {
+	v, ok := result["verdict"].(map[string]interface{})
+	if summary, isBenchmark := result["summary"].(map[string]interface{}); isBenchmark {
+		v, ok = summary["verdict"].(map[string]interface{})
+	}
+	if !ok || v["pass"] != false {
+		return nil
+	}
+	reasons, _ := v["reasons"].([]string)
+	return fmt.Errorf("verdict: FAIL: %s", strings.Join(reasons, "; "))
+}
+
+func printJSON(result map[string]interface{}) error {
 	output, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal result: %w", err)
 	}
 	fmt.Println(string(output))
+	return nil
+}
+
+// detectFromFile runs the detection pipeline on a PCD file without connecting to a machine.
+func detectFromFile(ctx context.Context, path string, cfg *Config, logger logging.Logger) error {
+	cloud, err := pc.NewFromFile(path, pc.BasicType)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	logger.Infof("Loaded %d points from %s", cloud.Size(), path)
+	cfg.applyDefaults()
+
+	// A PCD file has no world pose, so only a camera-frame region of interest applies.
+	objects, crop, err := detectObjectsInCloud(ctx, cloud, cfg, nil)
+	if err != nil {
+		return fmt.Errorf("detection failed: %w", err)
+	}
+	// A PCD file has no world frame to sort by.
+	sel := selection{Strategy: cfg.Selection, Point: cfg.SelectionPoint}
+	if err := sortDetections(objects, sel, nil, nil); err != nil {
+		return err
+	}
+	resp := detectionResponse(objects)
+	resp["selection"] = sel.strategy()
+	if cfg.Segmentation.ROI != nil {
+		resp["roi"] = crop.toMap()
+	}
+	return printJSON(resp)
+}
+
+// runCommand sends a DoCommand and, if it started a background job, streams the job's
+// progress to stderr until it finishes. Ctrl-C cancels the job on the service.
+func runCommand(ctx context.Context, svc resource.Resource, cmd map[string]interface{}) (map[string]interface{}, error) {
+	result, err := svc.DoCommand(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	jobID, ok := result["job_id"].(string)
+	if !ok {
+		return result, nil
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	reported := 0
+	for {
+		select {
+		case <-interrupt:
+			fmt.Fprintf(os.Stderr, "Interrupted, cancelling job %s...\n", jobID)
+			if _, err := svc.DoCommand(ctx, map[string]interface{}{"command": "cancel", "job_id": jobID}); err != nil {
+				fmt.Fprintf(os.Stderr, "Cancel failed: %v\n", err)
+			}
+		case <-ticker.C:
+		}
+
+		status, err := svc.DoCommand(ctx, map[string]interface{}{"command": "status", "job_id": jobID})
+		if err != nil {
+			return nil, fmt.Errorf("polling job %s: %w", jobID, err)
+		}
+		j, _ := status["job"].(map[string]interface{})
+		steps, _ := j["steps_completed"].([]string)
+		for ; reported < len(steps); reported++ {
+			fmt.Fprintf(os.Stderr, "[%s] %s\n", jobID, steps[reported])
+		}
+
+		switch j["state"] {
+		case jobSucceeded:
+			result, _ := j["result"].(map[string]interface{})
+			return result, nil
+		case jobFailed, jobCancelled:
+			return nil, fmt.Errorf("job %s %s: %v", jobID, j["state"], j["error"])
+		}
+	}
+}
+
+// collectCorrectionPicks runs the requested number of pick-and-place cycles so the service
+// accumulates calibration samples, waiting for the operator between picks.
+func collectCorrectionPicks(
+	ctx context.Context, svc resource.Resource, deps resource.Dependencies, cfg *Config, picks, objectIndex int,
+) error {
+	grip, err := gripper.FromDependencies(deps, cfg.Gripper)
+	if err != nil {
+		return fmt.Errorf("getting gripper %q: %w", cfg.Gripper, err)
+	}
+	stdin := bufio.NewReader(os.Stdin)
 
+	for i := 1; i <= picks; i++ {
+		fmt.Fprintf(os.Stderr, "Place the object for pick %d/%d and press Enter...", i, picks)
+		if _, err := stdin.ReadString('\n'); err != nil {
+			return fmt.Errorf("reading from stdin: %w", err)
+		}
+
+		result, err := runCommand(ctx, svc, map[string]interface{}{
+			"command": "pick", "object_index": float64(objectIndex), "place": true,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Pick %d/%d failed: %v\n", i, picks, err)
+			continue
+		}
+		place, _ := result["place"].(map[string]interface{})
+		offset, _ := place["place_offset_mm"].(map[string]interface{})
+		fmt.Fprintf(os.Stderr, "Pick %d/%d: success=%v, place offset=%vmm\n",
+			i, picks, result["success"], offset["total"])
+
+		// Make sure the object is released even if the place did not run.
+		if err := grip.Open(ctx, nil); err != nil {
+			return fmt.Errorf("failed to release object: %w", err)
+		}
+	}
 	return nil
 }
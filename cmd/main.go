@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/module"
 	"go.viam.com/rdk/resource"
 	generic "go.viam.com/rdk/services/generic"
@@ -20,14 +21,38 @@ Usage:
 Commands:
   detect    Capture a point cloud and detect objects via plane segmentation + clustering.
             Returns object positions in the camera (or detection) frame.
+            With --pcd, runs offline on a saved point cloud file.
+
+  capture   Save the camera's current point cloud to a PCD file.
 
   pick      Detect objects, then execute a full pick sequence on one of them:
             open gripper -> approach -> re-detect -> grasp -> grab -> lift -> verify.
             Reports calibration accuracy (approach offset and world-frame offset in mm).
 
+  benchmark Run repeated pick-and-place cycles on one object and summarize offsets,
+            success rate and step timings (mean, std-dev, percentiles).
+
+  sweep     Observe the same object from many arm poses and report the spread of its
+            apparent world position. The main calibration acceptance metric.
+
+  present   For a static (eye-to-hand) camera: show a target held by the arm to the
+            camera at many poses and compare where it is seen with where the arm holds it.
+
+  correct   Run several picks, then solve for a corrected camera-to-arm transform from
+            the paired detections and gripper positions. Prints a frame system snippet.
+
+  touch     Touch the top of a detected object with the tool tip (e.g. a probe) and
+            report the world-frame error. Needs no gripper.
+
   move-to   Incrementally move the gripper to a world-frame coordinate using the
             motion service. Useful for testing reachability and collision geometry.
 
+  sensitivity
+            Inject known camera calibration errors into a simulated cell and report
+            which ones pick-and-place (or sweep) detects and recovers. No machine needed.
+
+  history   Summarize recorded pick/sweep results and offset trends from a history file.
+
   status    Return the current service status and last result.
 
 Run 'hand-eye-test <command> --help' for flag details on a specific command.
@@ -42,7 +67,7 @@ func main() {
 	// Otherwise, run as a Viam module (viam-server passes a socket path as arg).
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
-		case "detect", "pick", "move-to", "status":
+		case "detect", "capture", "pick", "benchmark", "sweep", "present", "correct", "touch", "move-to", "sensitivity", "history", "status":
 			handeyetest.RunCLI(os.Args[1], os.Args[2:])
 			return
 		case "--help", "-help", "-h", "help":
@@ -53,5 +78,6 @@ func main() {
 
 	module.ModularMain(
 		resource.APIModel{API: generic.API, Model: handeyetest.Model},
+		resource.APIModel{API: sensor.API, Model: handeyetest.HealthModel},
 	)
 }
@@ -4,18 +4,28 @@ import (
 	"fmt"
 
 	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/resource"
 	generic "go.viam.com/rdk/services/generic"
+	"go.viam.com/rdk/spatialmath"
 )
 
 var Model = resource.NewModel("shannon", "hand-eye-test", "calibration-tester")
 
+// HealthModel is a sensor reporting the recent results of a calibration-tester.
+var HealthModel = resource.NewModel("shannon", "hand-eye-test", "calibration-health")
+
 func init() {
 	resource.RegisterService(generic.API, Model,
 		resource.Registration[resource.Resource, *Config]{
 			Constructor: newHandEyeTest,
 		},
 	)
+	resource.RegisterComponent(sensor.API, HealthModel,
+		resource.Registration[sensor.Sensor, *HealthConfig]{
+			Constructor: newCalibrationHealth,
+		},
+	)
 }
 
 type SegmentationConfig struct {
@@ -28,6 +38,8 @@ type SegmentationConfig struct {
 	MeanKFiltering     int       `json:"mean_k_filtering"`
 	MaxDepthMm         float64   `json:"max_depth_mm"`
 	MaxPointCount      int       `json:"max_point_count"`
+	// ROI crops the point cloud before segmentation (nil for the whole cloud).
+	ROI *ROIConfig `json:"roi"`
 }
 
 func (sc *SegmentationConfig) groundNormalVec() r3.Vector {
@@ -37,15 +49,259 @@ func (sc *SegmentationConfig) groundNormalVec() r3.Vector {
 	return r3.Vector{X: 0, Y: 0, Z: 1}
 }
 
+// ROIConfig is a region of interest that crops the point cloud before segmentation. MinMm
+// and MaxMm are opposite corners of an axis-aligned box. Polygon is a list of [x, y]
+// vertices that a point must fall inside when projected along Z, which in world frame is
+// an outline on a level table. Both are in Frame: "world" (the default), or "camera" for
+// the cloud's own frame. Either may be left out, but not both.
+type ROIConfig struct {
+	Frame   string      `json:"frame"`
+	MinMm   []float64   `json:"min_mm"`
+	MaxMm   []float64   `json:"max_mm"`
+	Polygon [][]float64 `json:"polygon_mm"`
+}
+
+func (roi *ROIConfig) validate() error {
+	if roi.Frame != "" && roi.Frame != roiWorld && roi.Frame != roiCamera {
+		return fmt.Errorf("segmentation.roi.frame must be %q or %q, got %q", roiWorld, roiCamera, roi.Frame)
+	}
+	if (roi.MinMm == nil) != (roi.MaxMm == nil) {
+		return fmt.Errorf("segmentation.roi needs both min_mm and max_mm for a box")
+	}
+	if roi.MinMm != nil {
+		if len(roi.MinMm) != 3 || len(roi.MaxMm) != 3 {
+			return fmt.Errorf("segmentation.roi.min_mm and max_mm must be [x, y, z]")
+		}
+		for i := range roi.MinMm {
+			if roi.MinMm[i] > roi.MaxMm[i] {
+				return fmt.Errorf("segmentation.roi.min_mm %v is not below max_mm %v", roi.MinMm, roi.MaxMm)
+			}
+		}
+	}
+	if roi.Polygon != nil {
+		if len(roi.Polygon) < 3 {
+			return fmt.Errorf("segmentation.roi.polygon_mm needs at least 3 vertices, got %d", len(roi.Polygon))
+		}
+		for _, v := range roi.Polygon {
+			if len(v) != 2 {
+				return fmt.Errorf("segmentation.roi.polygon_mm vertices must be [x, y], got %v", v)
+			}
+		}
+	}
+	if roi.MinMm == nil && roi.Polygon == nil {
+		return fmt.Errorf("segmentation.roi needs a box (min_mm, max_mm) or a polygon_mm")
+	}
+	return nil
+}
+
+// FiducialConfig configures the fiducial detector. MarkerSizeMm is the printed side of a
+// marker's black square; if set, markers whose measured size differs by more than 20%
+// are rejected. IDs limits detection to those markers (all if empty).
+type FiducialConfig struct {
+	MarkerSizeMm float64 `json:"marker_size_mm"`
+	IDs          []int   `json:"ids"`
+}
+
+// CheckerboardConfig describes the board for the checkerboard detector: InnerCorners is
+// the number of inner corners along a row and down a column, SquareSizeMm the side of a
+// square. One count must be odd and the other even, so that the board looks different
+// turned around.
+type CheckerboardConfig struct {
+	InnerCorners []int   `json:"inner_corners"`
+	SquareSizeMm float64 `json:"square_size_mm"`
+}
+
+func (cc *CheckerboardConfig) validate() error {
+	if len(cc.InnerCorners) != 2 || cc.InnerCorners[0] < 2 || cc.InnerCorners[1] < 2 {
+		return fmt.Errorf("checkerboard.inner_corners must be [columns, rows], each at least 2")
+	}
+	if (cc.InnerCorners[0]+cc.InnerCorners[1])%2 == 0 {
+		return fmt.Errorf("checkerboard.inner_corners %v would be ambiguous turned around; one count must be odd and the other even",
+			cc.InnerCorners)
+	}
+	if cc.SquareSizeMm <= 0 {
+		return fmt.Errorf("checkerboard.square_size_mm must be positive")
+	}
+	return nil
+}
+
+// PoseConfig is a gripper pose in the world frame. The orientation is an orientation
+// vector in degrees; if left empty the gripper points straight down.
+type PoseConfig struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Z     float64 `json:"z"`
+	OX    float64 `json:"o_x"`
+	OY    float64 `json:"o_y"`
+	OZ    float64 `json:"o_z"`
+	Theta float64 `json:"theta"`
+}
+
+func (pc PoseConfig) pose() spatialmath.Pose {
+	ov := &spatialmath.OrientationVectorDegrees{OX: pc.OX, OY: pc.OY, OZ: pc.OZ, Theta: pc.Theta}
+	if pc.OX == 0 && pc.OY == 0 && pc.OZ == 0 {
+		ov.OZ = -1
+	}
+	return spatialmath.NewPose(r3.Vector{X: pc.X, Y: pc.Y, Z: pc.Z}, ov)
+}
+
+// SweepConfig describes the observation poses for a sweep. Either list Poses explicitly,
+// or give a Target with RadiusMm, HeightMm and Count to generate a ring of poses that
+// all look at the target.
+type SweepConfig struct {
+	Poses    []PoseConfig `json:"poses"`
+	Target   []float64    `json:"target"`
+	RadiusMm float64      `json:"radius_mm"`
+	HeightMm float64      `json:"height_mm"`
+	Count    int          `json:"count"`
+}
+
+func (sc *SweepConfig) validate() error {
+	if len(sc.Poses) > 0 {
+		return nil
+	}
+	if len(sc.Target) != 3 || sc.Count <= 0 {
+		return fmt.Errorf("sweep needs either 'poses' or 'target' with 'count' > 0")
+	}
+	if sc.RadiusMm == 0 && sc.HeightMm == 0 {
+		return fmt.Errorf("sweep needs a nonzero 'radius_mm' or 'height_mm'; with neither, every pose is at the target")
+	}
+	return nil
+}
+
+// PlaceConfig controls the optional place phase after a pick. Without a DropPose the
+// object is put back where it was picked from.
+type PlaceConfig struct {
+	Enabled  bool        `json:"enabled"`
+	DropPose *PoseConfig `json:"drop_pose"`
+}
+
+// PresentConfig describes the poses for a presentation sweep, the validation sweep for a
+// static camera: the arm holds a target and shows it to the camera at each pose. Either
+// list Poses explicitly, or give a Center with RadiusMm and Count to generate the center
+// plus a ring of poses around it, all with the gripper pointing straight down.
+// TargetOffsetMm is the point the camera detects on the target (e.g. the center of its
+// top face) in the gripper frame.
+type PresentConfig struct {
+	Poses          []PoseConfig `json:"poses"`
+	Center         []float64    `json:"center"`
+	RadiusMm       float64      `json:"radius_mm"`
+	Count          int          `json:"count"`
+	TargetOffsetMm []float64    `json:"target_offset_mm"`
+}
+
+// ThresholdsConfig sets the calibration accuracy a pick must reach to pass, beyond
+// holding the object. A zero limit is not checked.
+type ThresholdsConfig struct {
+	// MaxWorldOffsetMm limits the world-frame offset, measured from where the gripper was
+	// meant to be (grasp_depth_offset_mm above the detected point).
+	MaxWorldOffsetMm    float64 `json:"max_world_offset_mm"`
+	MaxWorldOffsetXMm   float64 `json:"max_world_offset_x_mm"`
+	MaxWorldOffsetYMm   float64 `json:"max_world_offset_y_mm"`
+	MaxWorldOffsetZMm   float64 `json:"max_world_offset_z_mm"`
+	MaxApproachOffsetMm float64 `json:"max_approach_offset_mm"`
+	// MaxRedetectDriftMm limits the place offset: how far from its target the placed
+	// object is re-detected. Only checked when the pick places.
+	MaxRedetectDriftMm float64 `json:"max_redetect_drift_mm"`
+}
+
+func (tc *ThresholdsConfig) validate() error {
+	for name, v := range map[string]float64{
+		"max_world_offset_mm":    tc.MaxWorldOffsetMm,
+		"max_world_offset_x_mm":  tc.MaxWorldOffsetXMm,
+		"max_world_offset_y_mm":  tc.MaxWorldOffsetYMm,
+		"max_world_offset_z_mm":  tc.MaxWorldOffsetZMm,
+		"max_approach_offset_mm": tc.MaxApproachOffsetMm,
+		"max_redetect_drift_mm":  tc.MaxRedetectDriftMm,
+	} {
+		if v < 0 {
+			return fmt.Errorf("thresholds.%s must not be negative", name)
+		}
+	}
+	return nil
+}
+
 type Config struct {
-	Arm                string             `json:"arm"`
-	Camera             string             `json:"camera"`
-	Gripper            string             `json:"gripper"`
-	DetectionFrame     string             `json:"detection_frame"`
-	ApproachOffsetMm   float64            `json:"approach_offset_mm"`
-	GraspDepthOffsetMm float64            `json:"grasp_depth_offset_mm"`
-	LiftHeightMm       float64            `json:"lift_height_mm"`
-	Segmentation       SegmentationConfig `json:"segmentation"`
+	Arm                  string             `json:"arm"`
+	Camera               string             `json:"camera"`
+	Gripper              string             `json:"gripper"`
+	ToolFrame            string             `json:"tool_frame"`
+	CameraMount          string             `json:"camera_mount"`
+	DetectionFrame       string             `json:"detection_frame"`
+	CameraParentFrame    string             `json:"camera_parent_frame"`
+	ApproachOffsetMm     float64            `json:"approach_offset_mm"`
+	GraspDepthOffsetMm   float64            `json:"grasp_depth_offset_mm"`
+	LiftHeightMm         float64            `json:"lift_height_mm"`
+	GraspOrientation     string             `json:"grasp_orientation"`
+	GraspThetaDeg        float64            `json:"grasp_theta_deg"`
+	TouchStandoffMm      float64            `json:"touch_standoff_mm"`
+	RedetectGateMm       float64            `json:"redetect_gate_mm"`
+	Selection            string             `json:"selection"`
+	SelectionPoint       []float64          `json:"selection_point"`
+	MaxDetectionAgeSec   float64            `json:"max_detection_age_sec"`
+	StaleDetection       string             `json:"stale_detection"`
+	Detector             string             `json:"detector"`
+	Frames               int                `json:"frames"`
+	Segmentation         SegmentationConfig `json:"segmentation"`
+	Fiducial             FiducialConfig     `json:"fiducial"`
+	Checkerboard         CheckerboardConfig `json:"checkerboard"`
+	Sweep                SweepConfig        `json:"sweep"`
+	Place                PlaceConfig        `json:"place"`
+	Present              PresentConfig      `json:"present"`
+	ClearPose            *PoseConfig        `json:"clear_pose"`
+	Thresholds           ThresholdsConfig   `json:"thresholds"`
+	BlockDetectWhileBusy bool               `json:"block_detect_while_busy"`
+	HistoryFile          string             `json:"history_file"`
+}
+
+// detectionFrame returns the frame detected object centers are reported in.
+func (cfg *Config) detectionFrame() string {
+	if cfg.DetectionFrame != "" {
+		return cfg.DetectionFrame
+	}
+	return cfg.Camera
+}
+
+// toolFrame returns the frame that is moved onto targets: tool_frame if set (e.g. a
+// probe tip), otherwise the gripper.
+func (cfg *Config) toolFrame() string {
+	if cfg.ToolFrame != "" {
+		return cfg.ToolFrame
+	}
+	return cfg.Gripper
+}
+
+// cameraParentFrame returns the frame the camera is mounted to in the frame system,
+// which is the frame the hand-eye transform is expressed in.
+func (cfg *Config) cameraParentFrame() string {
+	if cfg.CameraParentFrame != "" {
+		return cfg.CameraParentFrame
+	}
+	return cfg.Arm
+}
+
+// cameraMount returns how the camera is mounted, defaulting to eye_in_hand.
+func (cfg *Config) cameraMount() string {
+	if cfg.CameraMount != "" {
+		return cfg.CameraMount
+	}
+	return cameraEyeInHand
+}
+
+// detector returns the object detector, defaulting to point cloud segmentation.
+func (cfg *Config) detector() string {
+	if cfg.Detector != "" {
+		return cfg.Detector
+	}
+	return detectorPointCloud
+}
+
+// graspOrientation returns the grasp orientation mode, defaulting to keep_current.
+func (cfg *Config) graspOrientation() string {
+	if cfg.GraspOrientation != "" {
+		return cfg.GraspOrientation
+	}
+	return graspKeepCurrent
 }
 
 func (cfg *Config) Validate(path string) ([]string, []string, error) {
@@ -55,15 +311,87 @@ func (cfg *Config) Validate(path string) ([]string, []string, error) {
 	if cfg.Camera == "" {
 		return nil, nil, fmt.Errorf("%s: camera is required", path)
 	}
-	if cfg.Gripper == "" {
-		return nil, nil, fmt.Errorf("%s: gripper is required", path)
+	if cfg.Gripper == "" && cfg.ToolFrame == "" {
+		return nil, nil, fmt.Errorf("%s: gripper or tool_frame is required", path)
+	}
+	if cfg.TouchStandoffMm < 0 {
+		return nil, nil, fmt.Errorf("%s: touch_standoff_mm must not be negative", path)
+	}
+	if err := validateSelection(cfg.Selection, cfg.SelectionPoint); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.MaxDetectionAgeSec < 0 {
+		return nil, nil, fmt.Errorf("%s: max_detection_age_sec must not be negative", path)
+	}
+	if err := validateStaleDetection(cfg.StaleDetection); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.RedetectGateMm < 0 {
+		return nil, nil, fmt.Errorf("%s: redetect_gate_mm must not be negative", path)
+	}
+	if err := validateCameraMount(cfg.CameraMount); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.cameraMount() == cameraEyeToHand && cfg.Thresholds.MaxApproachOffsetMm > 0 {
+		return nil, nil, fmt.Errorf("%s: thresholds.max_approach_offset_mm cannot be checked with an %s camera", path, cameraEyeToHand)
+	}
+	if err := validateGraspOrientation(cfg.GraspOrientation); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := validateDetector(cfg.Detector); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
 	}
+	if roi := cfg.Segmentation.ROI; roi != nil {
+		if err := roi.validate(); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if cfg.detector() != detectorPointCloud {
+			return nil, nil, fmt.Errorf("%s: segmentation.roi only applies to the %s detector", path, detectorPointCloud)
+		}
+	}
+	if cfg.Frames < 0 {
+		return nil, nil, fmt.Errorf("%s: frames must not be negative", path)
+	}
+	if cfg.Fiducial.MarkerSizeMm < 0 {
+		return nil, nil, fmt.Errorf("%s: fiducial.marker_size_mm must not be negative", path)
+	}
+	if cfg.detector() == detectorCheckerboard {
+		if err := cfg.Checkerboard.validate(); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for _, id := range cfg.Fiducial.IDs {
+		if id < 0 || id > markerMaxID {
+			return nil, nil, fmt.Errorf("%s: fiducial.ids must be between 0 and %d, got %d", path, markerMaxID, id)
+		}
+	}
+	if err := cfg.Thresholds.validate(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if sc := cfg.Sweep; len(sc.Poses) > 0 || sc.Target != nil {
+		if err := sc.validate(); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	deps := []string{cfg.Arm, cfg.Camera}
+	if cfg.Gripper != "" {
+		deps = append(deps, cfg.Gripper)
+	}
+	return deps, nil, nil
+}
+
+// applyDefaults fills in unset options. NewHandEyeTest applies them, so a config built in
+// code gets them without going through Validate.
+func (cfg *Config) applyDefaults() {
 	if cfg.ApproachOffsetMm == 0 {
 		cfg.ApproachOffsetMm = 100
 	}
 	if cfg.LiftHeightMm == 0 {
 		cfg.LiftHeightMm = 50
 	}
+	if cfg.RedetectGateMm == 0 {
+		cfg.RedetectGateMm = 30
+	}
 	if cfg.Segmentation.MinPtsInPlane == 0 {
 		cfg.Segmentation.MinPtsInPlane = 1500
 	}
@@ -82,6 +410,30 @@ func (cfg *Config) Validate(path string) ([]string, []string, error) {
 	if cfg.Segmentation.MeanKFiltering == 0 {
 		cfg.Segmentation.MeanKFiltering = 50
 	}
-	deps := []string{cfg.Arm, cfg.Camera, cfg.Gripper}
-	return deps, nil, nil
+}
+
+// HealthConfig configures the calibration-health sensor. Tester names the
+// calibration-tester service whose history it reports; Window is how many recent picks
+// the success rate covers.
+type HealthConfig struct {
+	Tester string `json:"tester"`
+	Window int    `json:"window"`
+}
+
+func (cfg *HealthConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.Tester == "" {
+		return nil, nil, fmt.Errorf("%s: tester is required", path)
+	}
+	if cfg.Window < 0 {
+		return nil, nil, fmt.Errorf("%s: window must not be negative", path)
+	}
+	return []string{cfg.Tester}, nil, nil
+}
+
+// applyDefaults fills in unset options. NewCalibrationHealth applies them, so a config
+// built in code gets them without going through Validate.
+func (cfg *HealthConfig) applyDefaults() {
+	if cfg.Window == 0 {
+		cfg.Window = 20
+	}
 }
@@ -0,0 +1,292 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+	"gonum.org/v1/gonum/mat"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// calibrationSample pairs an object detection in the camera frame with the world position
+// the gripper placed that object at. The camera parent pose is captured at detection time
+// so the sample stays valid after the arm moves.
+//
+// Samples come from placed objects rather than picked ones. A pick cannot reveal a camera
+// error: the gripper goes where the camera says the object is, so the gripper position
+// and the detection agree however wrong the camera pose is. A placed object sits where the
+// gripper put it, independent of the camera (assuming the closing jaws pulled it onto the
+// gripper axis), so re-detecting it pairs a camera-frame point with a known world position.
+type calibrationSample struct {
+	CameraPoint r3.Vector
+	ParentPose  spatialmath.Pose
+	PlacedWorld r3.Vector
+}
+
+// parentPoint returns where the gripper placed the object, expressed in the camera's parent frame.
+func (cs calibrationSample) parentPoint() r3.Vector {
+	return spatialmath.Compose(spatialmath.PoseInverse(cs.ParentPose), spatialmath.NewPoseFromPoint(cs.PlacedWorld)).Point()
+}
+
+type residualStats struct {
+	MeanMm float64
+	RMSMm  float64
+	MaxMm  float64
+}
+
+func (r residualStats) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"mean_mm": r.MeanMm,
+		"rms_mm":  r.RMSMm,
+		"max_mm":  r.MaxMm,
+	}
+}
+
+type correctionResult struct {
+	SampleCount    int
+	ParentFrame    string
+	Current        spatialmath.Pose
+	Corrected      spatialmath.Pose
+	ResidualBefore residualStats
+	ResidualAfter  residualStats
+}
+
+func (r *correctionResult) toMap() map[string]interface{} {
+	delta := spatialmath.PoseDelta(r.Current, r.Corrected)
+	return map[string]interface{}{
+		"sample_count":       r.SampleCount,
+		"residual_before_mm": r.ResidualBefore.toMap(),
+		"residual_after_mm":  r.ResidualAfter.toMap(),
+		"current_transform":  poseToFrameMap(r.ParentFrame, r.Current),
+		"frame":              poseToFrameMap(r.ParentFrame, r.Corrected),
+		"change": map[string]interface{}{
+			"translation_mm": vecNorm(r.Corrected.Point().Sub(r.Current.Point())),
+			"rotation_deg":   delta.Orientation().AxisAngles().Theta * 180 / math.Pi,
+		},
+	}
+}
+
+// poseToFrameMap renders a pose as a Viam frame system "frame" block.
+func poseToFrameMap(parent string, p spatialmath.Pose) map[string]interface{} {
+	pt := p.Point()
+	ov := p.Orientation().OrientationVectorDegrees()
+	return map[string]interface{}{
+		"parent": parent,
+		"translation": map[string]interface{}{
+			"x": pt.X, "y": pt.Y, "z": pt.Z,
+		},
+		"orientation": map[string]interface{}{
+			"type": "ov_degrees",
+			"value": map[string]interface{}{
+				"x": ov.OX, "y": ov.OY, "z": ov.OZ, "th": ov.Theta,
+			},
+		},
+	}
+}
+
+// computeResiduals measures how far each sample's camera point lands from where the
+// gripper placed the object when mapped through the given camera-to-parent transform.
+func computeResiduals(samples []calibrationSample, cameraToParent spatialmath.Pose) residualStats {
+	var stats residualStats
+	if len(samples) == 0 {
+		return stats
+	}
+	var sum, sumSq float64
+	for _, s := range samples {
+		predicted := spatialmath.Compose(cameraToParent, spatialmath.NewPoseFromPoint(s.CameraPoint)).Point()
+		d := vecNorm(predicted.Sub(s.parentPoint()))
+		sum += d
+		sumSq += d * d
+		stats.MaxMm = math.Max(stats.MaxMm, d)
+	}
+	n := float64(len(samples))
+	stats.MeanMm = sum / n
+	stats.RMSMm = math.Sqrt(sumSq / n)
+	return stats
+}
+
+// minRotationSpreadMm is how far the samples' camera-frame points must spread in a
+// second direction before the rotation is trusted over detection noise.
+const minRotationSpreadMm = 20
+
+// solveCameraCorrection fits a camera-to-parent transform that best maps the sampled
+// camera-frame detections onto the positions the gripper placed the object at, in the
+// least-squares sense. With translationOnly the current rotation is kept and only the
+// offset is refit, which needs fewer and less varied samples.
+func solveCameraCorrection(samples []calibrationSample, current spatialmath.Pose, translationOnly bool) (spatialmath.Pose, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no calibration samples collected; run some picks with place first")
+	}
+
+	if translationOnly {
+		rot := current.Orientation().RotationMatrix()
+		var sum r3.Vector
+		for _, s := range samples {
+			sum = sum.Add(s.parentPoint().Sub(rot.Mul(s.CameraPoint)))
+		}
+		return spatialmath.NewPose(sum.Mul(1/float64(len(samples))), current.Orientation()), nil
+	}
+
+	if len(samples) < 3 {
+		return nil, fmt.Errorf("need at least 3 samples to solve for rotation, have %d (or use translation_only)", len(samples))
+	}
+
+	// Kabsch: find R, t minimizing sum |R*p + t - q|^2.
+	var pMean, qMean r3.Vector
+	for _, s := range samples {
+		pMean = pMean.Add(s.CameraPoint)
+		qMean = qMean.Add(s.parentPoint())
+	}
+	n := float64(len(samples))
+	pMean = pMean.Mul(1 / n)
+	qMean = qMean.Mul(1 / n)
+
+	// The rotation is only observable if the camera saw the object at well separated
+	// points; re-detections that all land near the image center cannot pin it down.
+	scatter := mat.NewSymDense(3, nil)
+	for _, s := range samples {
+		p := s.CameraPoint.Sub(pMean)
+		pv := [3]float64{p.X, p.Y, p.Z}
+		for i := 0; i < 3; i++ {
+			for j := i; j < 3; j++ {
+				scatter.SetSym(i, j, scatter.At(i, j)+pv[i]*pv[j])
+			}
+		}
+	}
+	var eig mat.EigenSym
+	if !eig.Factorize(scatter, false) {
+		return nil, fmt.Errorf("eigen decomposition of sample scatter failed")
+	}
+	// Eigenvalues are ascending; the middle one is the spread across the main direction.
+	if spread := math.Sqrt(eig.Values(nil)[1] / n); spread < minRotationSpreadMm {
+		return nil, fmt.Errorf("camera-frame samples spread only %.1fmm across (need %.0fmm) to solve for rotation; "+
+			"vary the object position in the image between picks (or use translation_only)", spread, float64(minRotationSpreadMm))
+	}
+
+	h := mat.NewDense(3, 3, nil)
+	for _, s := range samples {
+		p := s.CameraPoint.Sub(pMean)
+		q := s.parentPoint().Sub(qMean)
+		pv := [3]float64{p.X, p.Y, p.Z}
+		qv := [3]float64{q.X, q.Y, q.Z}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				h.Set(i, j, h.At(i, j)+pv[i]*qv[j])
+			}
+		}
+	}
+
+	var svd mat.SVD
+	if !svd.Factorize(h, mat.SVDFull) {
+		return nil, fmt.Errorf("SVD of sample covariance failed")
+	}
+	values := svd.Values(nil)
+	if values[0] == 0 || values[1]/values[0] < 1e-3 {
+		return nil, fmt.Errorf("samples are collinear; pick the object at more varied positions")
+	}
+	var u, v mat.Dense
+	svd.UTo(&u)
+	svd.VTo(&v)
+
+	var r mat.Dense
+	r.Mul(&v, u.T())
+	if mat.Det(&r) < 0 {
+		// Reflection: flip the axis with the smallest singular value.
+		d := mat.NewDiagDense(3, []float64{1, 1, -1})
+		var vd mat.Dense
+		vd.Mul(&v, d)
+		r.Mul(&vd, u.T())
+	}
+
+	rm, err := spatialmath.NewRotationMatrix([]float64{
+		r.At(0, 0), r.At(0, 1), r.At(0, 2),
+		r.At(1, 0), r.At(1, 1), r.At(1, 2),
+		r.At(2, 0), r.At(2, 1), r.At(2, 2),
+	})
+	if err != nil {
+		return nil, err
+	}
+	t := qMean.Sub(rm.Mul(pMean))
+	return spatialmath.NewPose(t, rm), nil
+}
+
+// recordPlaceSample keeps a calibration sample for solve_correction from the re-detection
+// of an object the gripper placed at placedWorld.
+func (s *handEyeTest) recordPlaceSample(ctx context.Context, obj DetectedObject, placedWorld r3.Vector) {
+	sample, err := s.placeSample(ctx, obj, s.cfg.detectionFrame(), placedWorld)
+	if err != nil {
+		s.logger.Warnf("Could not record calibration sample (non-fatal): %v", err)
+		return
+	}
+	s.mu.Lock()
+	s.samples = append(s.samples, *sample)
+	s.mu.Unlock()
+}
+
+// placeSample expresses a detection in the camera frame and captures the camera parent's
+// world pose, while the camera is still where it saw the object.
+func (s *handEyeTest) placeSample(
+	ctx context.Context, obj DetectedObject, detectionFrame string, placedWorld r3.Vector,
+) (*calibrationSample, error) {
+	cameraInDetection, err := s.motion.GetPose(ctx, s.cfg.Camera, detectionFrame, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get camera pose in %s frame: %w", detectionFrame, err)
+	}
+	parentPose, err := s.motion.GetPose(ctx, s.cfg.cameraParentFrame(), "world", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s world pose: %w", s.cfg.cameraParentFrame(), err)
+	}
+	cameraPoint := spatialmath.Compose(spatialmath.PoseInverse(cameraInDetection.Pose()), spatialmath.NewPoseFromPoint(obj.Center)).Point()
+	return &calibrationSample{
+		CameraPoint: cameraPoint,
+		ParentPose:  parentPose.Pose(),
+		PlacedWorld: placedWorld,
+	}, nil
+}
+
+func (s *handEyeTest) handleSolveCorrection(ctx context.Context, translationOnly, clear bool) (map[string]interface{}, error) {
+	result, err := s.solveCorrection(ctx, translationOnly)
+	if err != nil {
+		return nil, err
+	}
+	if clear {
+		s.mu.Lock()
+		s.samples = nil
+		s.mu.Unlock()
+	}
+	return result.toMap(), nil
+}
+
+// solveCorrection fits a corrected camera pose to the samples collected so far.
+func (s *handEyeTest) solveCorrection(ctx context.Context, translationOnly bool) (*correctionResult, error) {
+	s.mu.Lock()
+	samples := append([]calibrationSample(nil), s.samples...)
+	s.mu.Unlock()
+
+	parentFrame := s.cfg.cameraParentFrame()
+	currentPose, err := s.motion.GetPose(ctx, s.cfg.Camera, parentFrame, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current camera pose in %s frame: %w", parentFrame, err)
+	}
+	current := currentPose.Pose()
+
+	corrected, err := solveCameraCorrection(samples, current, translationOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &correctionResult{
+		SampleCount:    len(samples),
+		ParentFrame:    parentFrame,
+		Current:        current,
+		Corrected:      corrected,
+		ResidualBefore: computeResiduals(samples, current),
+		ResidualAfter:  computeResiduals(samples, corrected),
+	}
+	s.logger.Infof("Solved camera correction from %d samples: residual RMS %.2fmm -> %.2fmm",
+		result.SampleCount, result.ResidualBefore.RMSMm, result.ResidualAfter.RMSMm)
+	return result, nil
+}
@@ -3,23 +3,118 @@ package handeyetest
 import (
 	"context"
 	"fmt"
+	"math"
+	"os"
 
 	"github.com/golang/geo/r3"
 
 	"go.viam.com/rdk/components/camera"
 	pc "go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
 	"go.viam.com/rdk/vision/segmentation"
 )
 
-// DetectedObject represents an object found in the point cloud.
+// DetectedObject represents an object found in the point cloud. All geometry is in the
+// detection frame.
 type DetectedObject struct {
-	Center     r3.Vector
-	PointCount int
+	Center      r3.Vector
+	PointCount  int
+	BoundingBox BoundingBox
+	OrientedBox OrientedBox
+	// HeightMm is how far the object's top rises above the ground plane (0 if no plane was found).
+	HeightMm float64
+	// Plane is the ground plane found with the object, in the same frame (nil if none).
+	// Objects from one detection share it.
+	Plane *groundPlane
+	// Orientation and ID are set by detectors that measure them, such as the fiducial
+	// detector (nil otherwise). A marker's Z axis points out of its face.
+	Orientation spatialmath.Orientation
+	ID          *int
+	// ReprojectionErrorPx is the RMS distance, in pixels, between the image features a
+	// pose was solved from and where that pose puts them (0 if not solved from an image).
+	ReprojectionErrorPx float64
+	// Frames is how many frames the object was averaged over, and CenterStdDevMm the
+	// per-axis standard deviation of its center across them (0 for a single frame).
+	Frames         int
+	CenterStdDevMm r3.Vector
 }
 
-// detectObjects captures a point cloud from the camera and runs plane segmentation
-// followed by radius clustering to find objects. The returned centers are in the camera frame.
-func detectObjects(ctx context.Context, cam camera.Camera, cfg *Config) ([]DetectedObject, error) {
+// upDirection is the direction away from the surface the object was found on, in the
+// detection frame: out of a marker's face, or up from the ground plane. It is false if
+// the detector measured neither.
+func (obj DetectedObject) upDirection() (r3.Vector, bool) {
+	if obj.Orientation != nil {
+		return rotate(obj.Orientation, r3.Vector{Z: 1}), true
+	}
+	if obj.Plane != nil {
+		return obj.Plane.Normal, true
+	}
+	return r3.Vector{}, false
+}
+
+// topPoint is the center of the object's top surface, in the detection frame: a marker's
+// or board's center, or the cluster's center raised to its top along the ground plane
+// normal. It is false if the detector measured neither.
+func (obj DetectedObject) topPoint() (r3.Vector, bool) {
+	if obj.Orientation != nil {
+		return obj.Center, true
+	}
+	if obj.Plane != nil {
+		return obj.Center.Add(obj.Plane.Normal.Mul(obj.HeightMm - obj.Plane.signedDistance(obj.Center))), true
+	}
+	return r3.Vector{}, false
+}
+
+// detectObjects finds objects with the configured detector, averaged over cfg.Frames
+// frames if it is more than one. The returned centers are in the camera frame. cloudPose
+// is the camera's world pose, for a world-frame region of interest (nil if not needed).
+func detectObjects(
+	ctx context.Context, cam camera.Camera, cfg *Config, cloudPose spatialmath.Pose,
+) ([]DetectedObject, cropStats, error) {
+	if cfg.Frames > 1 {
+		return detectFrames(ctx, cam, cfg, cloudPose)
+	}
+	return detectFrame(ctx, cam, cfg, cloudPose)
+}
+
+// detectFrame finds objects in one frame. By default it captures a point cloud from the
+// camera and runs plane segmentation followed by radius clustering.
+func detectFrame(
+	ctx context.Context, cam camera.Camera, cfg *Config, cloudPose spatialmath.Pose,
+) ([]DetectedObject, cropStats, error) {
+	switch cfg.detector() {
+	case detectorFiducial:
+		objects, err := detectFiducials(ctx, cam, cfg)
+		return objects, cropStats{}, err
+	case detectorCheckerboard:
+		objects, err := detectCheckerboard(ctx, cam, cfg)
+		return objects, cropStats{}, err
+	}
+	cloud, err := cam.NextPointCloud(ctx, nil)
+	if err != nil {
+		return nil, cropStats{}, fmt.Errorf("failed to get point cloud: %w", err)
+	}
+	return detectObjectsInCloud(ctx, cloud, cfg, cloudPose)
+}
+
+// detectObjectsInCloud runs the detection pipeline on an already captured point cloud,
+// e.g. one loaded from a PCD file. Centers are in the cloud's frame. If the segmentation
+// config has a region of interest, the cloud is cropped to it first; cloudPose is the
+// cloud frame's world pose, which a world-frame region needs.
+func detectObjectsInCloud(
+	ctx context.Context, cloud pc.PointCloud, cfg *Config, cloudPose spatialmath.Pose,
+) ([]DetectedObject, cropStats, error) {
+	var crop cropStats
+	var outside pc.PointCloud
+	if roi := cfg.Segmentation.ROI; roi != nil {
+		inside, out, err := cropCloud(cloud, roi, cloudPose)
+		if err != nil {
+			return nil, crop, fmt.Errorf("failed to crop to the region of interest: %w", err)
+		}
+		cloud, outside = inside, out
+		crop.PointsRemoved = outside.Size()
+	}
+
 	segCfg := &segmentation.RadiusClusteringConfig{
 		MinPtsInPlane:      cfg.Segmentation.MinPtsInPlane,
 		MaxDistFromPlane:   cfg.Segmentation.MaxDistFromPlane,
@@ -31,16 +126,17 @@ func detectObjects(ctx context.Context, cam camera.Camera, cfg *Config) ([]Detec
 	}
 
 	if err := segCfg.CheckValid(); err != nil {
-		return nil, fmt.Errorf("invalid segmentation config: %w", err)
+		return nil, crop, fmt.Errorf("invalid segmentation config: %w", err)
 	}
 
-	objects, err := segCfg.RadiusClustering(ctx, cam)
+	plane, objects, removed, err := radiusClustering(ctx, cloud, outside, segCfg)
 	if err != nil {
-		return nil, fmt.Errorf("segmentation failed: %w", err)
+		return nil, crop, fmt.Errorf("segmentation failed: %w", err)
 	}
+	crop.ClustersRemoved = removed
 
 	if len(objects) == 0 {
-		return nil, nil
+		return nil, crop, nil
 	}
 
 	var detected []DetectedObject
@@ -52,13 +148,249 @@ func detectObjects(ctx context.Context, cam camera.Camera, cfg *Config) ([]Detec
 		if cfg.Segmentation.MaxPointCount > 0 && obj.Size() > cfg.Segmentation.MaxPointCount {
 			continue
 		}
+		points := clusterPoints(obj)
 		detected = append(detected, DetectedObject{
-			Center:     center,
-			PointCount: obj.Size(),
+			Center:      center,
+			PointCount:  obj.Size(),
+			BoundingBox: computeBoundingBox(points),
+			OrientedBox: computeOrientedBox(points, center),
+			HeightMm:    heightAbovePlane(points, plane),
+			Plane:       plane,
+		})
+	}
+
+	return detected, crop, nil
+}
+
+// radiusClustering finds the ground plane with rdk's ground-plane segmentation, filters
+// noise off the remaining points as rdk's radius clustering segmenter does, and groups
+// them into clusters of neighbors within the clustering radius. It also returns the plane
+// (nil if none was found), oriented toward the clusters.
+//
+// outside holds the points a region of interest cropped off the cloud (nil without one).
+// They play no part in finding the plane; the table is taken off them with the plane
+// found inside, and they are clustered in the same pass as the rest. Clusters that reach
+// into the region keep only their points inside it, and those that don't are counted as
+// removed.
+func radiusClustering(
+	ctx context.Context, cloud, outside pc.PointCloud, rcc *segmentation.RadiusClusteringConfig,
+) (*groundPlane, []pc.PointCloud, int, error) {
+	ps := segmentation.NewPointCloudGroundPlaneSegmentation(
+		cloud, rcc.MaxDistFromPlane, rcc.MinPtsInPlane, rcc.AngleTolerance, rcc.NormalVec)
+	foundPlane, nonPlane, err := ps.FindGroundPlane(ctx)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	var plane *groundPlane
+	if foundPlane != nil {
+		if plane, err = refineGroundPlane(foundPlane); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	var inside map[r3.Vector]bool
+	if outside != nil {
+		inside = make(map[r3.Vector]bool, nonPlane.Size())
+		all := pc.NewBasicEmpty()
+		nonPlane.Iterate(0, 0, func(p r3.Vector, d pc.Data) bool {
+			inside[p] = true
+			err = all.Set(p, d)
+			return err == nil
 		})
+		if err == nil {
+			outside.Iterate(0, 0, func(p r3.Vector, d pc.Data) bool {
+				if plane != nil && math.Abs(plane.signedDistance(p)) <= rcc.MaxDistFromPlane {
+					return true
+				}
+				err = all.Set(p, d)
+				return err == nil
+			})
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		nonPlane = all
+	}
+	if rcc.MeanKFiltering > 0 {
+		filter, err := pc.StatisticalOutlierFilter(rcc.MeanKFiltering, 1.25)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		out := nonPlane.CreateNewRecentered(spatialmath.NewZeroPose())
+		if err := filter(nonPlane, out); err != nil {
+			return nil, nil, 0, err
+		}
+		nonPlane = out
+	}
+
+	clusters, err := clusterByRadius(nonPlane, rcc.ClusteringRadiusMm, rcc.MinPtsInSegment)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	segments, removed := clusters, 0
+	if inside != nil {
+		segments = nil
+		for _, cluster := range clusters {
+			kept := pc.NewBasicEmpty()
+			cluster.Iterate(0, 0, func(p r3.Vector, d pc.Data) bool {
+				if inside[p] {
+					err = kept.Set(p, d)
+				}
+				return err == nil
+			})
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			switch {
+			case kept.Size() == 0:
+				removed++
+			case kept.Size() >= rcc.MinPtsInSegment:
+				segments = append(segments, kept)
+			}
+		}
+	}
+
+	// Objects sit on top of the table, so point the normal toward them.
+	if plane != nil && len(segments) > 0 {
+		var sum r3.Vector
+		for _, seg := range segments {
+			sum = sum.Add(computeCenter(seg))
+		}
+		plane.orientToward(sum.Mul(1 / float64(len(segments))))
+	}
+	return plane, segments, removed, nil
+}
+
+// clusterByRadius splits a cloud into the connected groups of points within radiusMm of a
+// neighbor, using the cloud's k-d tree, and drops groups of fewer than minPts points.
+func clusterByRadius(cloud pc.PointCloud, radiusMm float64, minPts int) ([]pc.PointCloud, error) {
+	kdt := pc.ToKDTree(cloud)
+	seen := make(map[r3.Vector]bool, cloud.Size())
+	var clusters []pc.PointCloud
+	var err error
+	kdt.Iterate(0, 0, func(p r3.Vector, d pc.Data) bool {
+		if seen[p] {
+			return true
+		}
+		seen[p] = true
+		cluster := pc.NewBasicEmpty()
+		stack := []*pc.PointAndData{{P: p, D: d}}
+		for len(stack) > 0 {
+			next := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if err = cluster.Set(next.P, next.D); err != nil {
+				return false
+			}
+			for _, neighbor := range kdt.RadiusNearestNeighbors(next.P, radiusMm, false) {
+				if !seen[neighbor.P] {
+					seen[neighbor.P] = true
+					stack = append(stack, neighbor)
+				}
+			}
+		}
+		clusters = append(clusters, cluster)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pc.PrunePointClouds(clusters, minPts), nil
+}
+
+// detectionResponse formats detected objects as the detect command's response.
+func detectionResponse(objects []DetectedObject) map[string]interface{} {
+	objList := make([]interface{}, len(objects))
+	for i, obj := range objects {
+		entry := map[string]interface{}{
+			"index":                 i,
+			"point_count":           obj.PointCount,
+			"center_x_mm":           obj.Center.X,
+			"center_y_mm":           obj.Center.Y,
+			"center_z_mm":           obj.Center.Z,
+			"bounding_box":          obj.BoundingBox.toMap(),
+			"oriented_bounding_box": obj.OrientedBox.toMap(),
+			"height_above_plane_mm": obj.HeightMm,
+		}
+		if obj.ID != nil {
+			entry["id"] = *obj.ID
+		}
+		if obj.Orientation != nil {
+			entry["orientation"] = orientationToMap(obj.Orientation)
+		}
+		if obj.ReprojectionErrorPx > 0 {
+			entry["reprojection_error_px"] = obj.ReprojectionErrorPx
+		}
+		if obj.Frames > 1 {
+			entry["frames"] = obj.Frames
+			entry["center_std_dev_mm"] = map[string]interface{}{
+				"x": obj.CenterStdDevMm.X, "y": obj.CenterStdDevMm.Y, "z": obj.CenterStdDevMm.Z,
+				"total": vecNorm(obj.CenterStdDevMm),
+			}
+		}
+		objList[i] = entry
+	}
+
+	resp := map[string]interface{}{
+		"objects": objList,
+		"count":   len(objects),
 	}
+	if len(objects) > 0 && objects[0].Plane != nil {
+		resp["ground_plane"] = objects[0].Plane.toMap()
+	}
+	return resp
+}
+
+// capturePointCloud saves the camera's current point cloud to a binary PCD file.
+func capturePointCloud(ctx context.Context, cam camera.Camera, path string) (int, error) {
+	cloud, err := cam.NextPointCloud(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get point cloud: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := pc.ToPCD(cloud, f, pc.PCDBinary); err != nil {
+		f.Close()
+		return 0, fmt.Errorf("failed to write PCD: %w", err)
+	}
+	return cloud.Size(), f.Close()
+}
+
+// toWorldFrame transforms a point expressed in the given frame into the world frame
+// using the motion service's current view of the frame system.
+func (s *handEyeTest) toWorldFrame(ctx context.Context, p r3.Vector, frame string) (r3.Vector, error) {
+	framePose, err := s.frameWorldPose(ctx, frame)
+	if err != nil {
+		return r3.Vector{}, err
+	}
+	return spatialmath.Compose(framePose, spatialmath.NewPoseFromPoint(p)).Point(), nil
+}
+
+// detect detects objects with the service's camera. A world-frame region of interest is
+// located from the detection frame's current world pose.
+func (s *handEyeTest) detect(ctx context.Context) ([]DetectedObject, cropStats, error) {
+	var cloudPose spatialmath.Pose
+	if roi := s.cfg.Segmentation.ROI; roi != nil && roi.frame() == roiWorld {
+		pose, err := s.frameWorldPose(ctx, s.cfg.detectionFrame())
+		if err != nil {
+			return nil, cropStats{}, fmt.Errorf("cannot locate the region of interest: %w", err)
+		}
+		cloudPose = pose
+	}
+	return detectObjects(ctx, s.camera, s.cfg, cloudPose)
+}
 
-	return detected, nil
+// frameWorldPose returns the current pose of a frame in the world frame.
+func (s *handEyeTest) frameWorldPose(ctx context.Context, frame string) (spatialmath.Pose, error) {
+	if frame == "world" {
+		return spatialmath.NewZeroPose(), nil
+	}
+	framePose, err := s.motion.GetPose(ctx, frame, "world", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s world pose: %w", frame, err)
+	}
+	return framePose.Pose(), nil
 }
 
 // computeCenter computes the mean position of all points in a point cloud.
@@ -0,0 +1,167 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/geo/r3"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"go.viam.com/rdk/motionplan"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// dryRunNote is returned with every dry run. The motion service can only chain plans from a
+// joint configuration, and the planned trajectories do not reliably carry one back.
+const dryRunNote = "each move is planned from the arm's current state, not from the previous move's end; " +
+	"the grasp and lift paths of the real pick may differ"
+
+// plannedMove is one gripper motion of a pick, computed and planned but not executed.
+type plannedMove struct {
+	Step          string
+	PoseDetection spatialmath.Pose
+	PoseWorld     spatialmath.Pose
+	Reachable     bool
+	Waypoints     int
+	Error         string
+}
+
+func (m plannedMove) toMap(detectionFrame string) map[string]interface{} {
+	detection := poseToMap(m.PoseDetection)
+	detection["frame"] = detectionFrame
+	world := poseToMap(m.PoseWorld)
+	world["frame"] = "world"
+	out := map[string]interface{}{
+		"step":           m.Step,
+		"pose_detection": detection,
+		"pose_world":     world,
+		"reachable":      m.Reachable,
+		"plan_waypoints": m.Waypoints,
+	}
+	if m.Error != "" {
+		out["error"] = m.Error
+	}
+	return out
+}
+
+// planPick turns an object's pickMotion, the same one executePick drives, into world-frame
+// approach, grasp and lift poses and asks the motion service to plan each one, without
+// moving anything. Every pose is planned from the arm's current state, not from the
+// previous planned pose, so a plan failure means the pose has no IK solution or cannot be
+// reached collision-free from where the arm is now. The result says so in its "note".
+func (s *handEyeTest) planPick(ctx context.Context, obj DetectedObject) (map[string]interface{}, error) {
+	s.mu.Lock()
+	s.currentStatus = "planning"
+	s.mu.Unlock()
+
+	detectionFrame := s.cfg.detectionFrame()
+	detectionWorld, err := s.frameWorldPose(ctx, detectionFrame)
+	if err != nil {
+		return nil, err
+	}
+	pm, err := s.pickMotion(ctx, obj, detectionFrame)
+	if err != nil {
+		return nil, err
+	}
+	approachWorld := pm.Approach.Pose()
+	if pm.Approach.Parent() != "world" {
+		approachWorld = spatialmath.Compose(detectionWorld, approachWorld)
+	}
+	graspWorld := spatialmath.NewPose(approachWorld.Point().Sub(pm.Up.Mul(pm.GraspDeltaMm)), approachWorld.Orientation())
+	liftWorld := spatialmath.NewPose(graspWorld.Point().Add(pm.Up.Mul(s.cfg.LiftHeightMm)), approachWorld.Orientation())
+
+	toDetection := spatialmath.PoseInverse(detectionWorld)
+	moves := []plannedMove{
+		{Step: "approach", PoseWorld: approachWorld},
+		{Step: "grasp_position", PoseWorld: graspWorld},
+		{Step: "lift", PoseWorld: liftWorld},
+	}
+	allReachable := true
+	rows := make([]interface{}, len(moves))
+	for i := range moves {
+		m := &moves[i]
+		m.PoseDetection = spatialmath.Compose(toDetection, m.PoseWorld)
+		m.Waypoints, err = s.planMove(ctx, m.PoseWorld)
+		if err != nil {
+			m.Error = err.Error()
+			allReachable = false
+			s.logger.Warnf("Dry run: %s is not reachable: %v", m.Step, err)
+		} else {
+			m.Reachable = true
+			s.logger.Infof("Dry run: %s planned with %d waypoints", m.Step, m.Waypoints)
+		}
+		rows[i] = m.toMap(detectionFrame)
+	}
+
+	objectWorld := spatialmath.Compose(detectionWorld, spatialmath.NewPoseFromPoint(obj.Center)).Point()
+	return map[string]interface{}{
+		"dry_run":   true,
+		"reachable": allReachable,
+		"detected_position": map[string]interface{}{
+			"x_mm": obj.Center.X, "y_mm": obj.Center.Y, "z_mm": obj.Center.Z, "frame": detectionFrame,
+		},
+		"object_position_world_frame": map[string]interface{}{
+			"x_mm": objectWorld.X, "y_mm": objectWorld.Y, "z_mm": objectWorld.Z, "frame": "world",
+		},
+		"grasp_yaw_deg": pm.YawDeg,
+		"moves":         rows,
+		"note":          dryRunNote,
+	}, nil
+}
+
+// armBaseUp returns the arm base frame's Z axis in world coordinates, the direction the
+// grasp and lift moves travel along. It falls back to world Z.
+func (s *handEyeTest) armBaseUp(ctx context.Context) r3.Vector {
+	base, err := s.armBasePose(ctx)
+	if err != nil {
+		s.logger.Warnf("Could not locate the arm base, assuming its Z is world Z: %v", err)
+		return r3.Vector{Z: 1}
+	}
+	return rotate(base.Orientation(), r3.Vector{Z: 1})
+}
+
+// armBasePose returns the arm base frame's pose in world, from where the arm driver and
+// the frame system each say the end effector is.
+func (s *handEyeTest) armBasePose(ctx context.Context) (spatialmath.Pose, error) {
+	endInBase, err := s.arm.EndPosition(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get arm position: %w", err)
+	}
+	endInWorld, err := s.frameWorldPose(ctx, s.cfg.Arm)
+	if err != nil {
+		return nil, err
+	}
+	return spatialmath.Compose(endInWorld, spatialmath.PoseInverse(endInBase)), nil
+}
+
+// planMove asks the builtin motion service to plan, but not execute, a move of the gripper
+// to a world-frame pose. It returns the number of waypoints in the planned trajectory.
+func (s *handEyeTest) planMove(ctx context.Context, pose spatialmath.Pose) (int, error) {
+	req := motion.MoveReq{
+		ComponentName: s.cfg.toolFrame(),
+		Destination:   referenceframe.NewPoseInFrame("world", pose),
+	}
+	reqPB, err := req.ToProto(s.motion.Name().ShortName())
+	if err != nil {
+		return 0, err
+	}
+	reqJSON, err := protojson.Marshal(reqPB)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.motion.DoCommand(ctx, map[string]interface{}{"plan": string(reqJSON)})
+	if err != nil {
+		return 0, err
+	}
+	// In-process the trajectory comes back typed; over the network it is a plain list.
+	switch traj := resp["plan"].(type) {
+	case motionplan.Trajectory:
+		return len(traj), nil
+	case []interface{}:
+		return len(traj), nil
+	default:
+		return 0, fmt.Errorf("motion service returned no plan")
+	}
+}
@@ -0,0 +1,218 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	pc "go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+)
+
+// Object detectors. Point cloud segmentation finds anything standing on the table, but a
+// cluster's centroid is biased toward the faces the camera can see. The fiducial detector
+// finds printed markers in the color image and measures their full pose instead. The
+// checkerboard detector finds one known board and solves its pose from the image alone.
+const (
+	detectorPointCloud   = "pointcloud"
+	detectorFiducial     = "fiducial"
+	detectorCheckerboard = "checkerboard"
+)
+
+func validateDetector(detector string) error {
+	switch detector {
+	case "", detectorPointCloud, detectorFiducial, detectorCheckerboard:
+		return nil
+	default:
+		return fmt.Errorf("detector must be %q, %q or %q, got %q",
+			detectorPointCloud, detectorFiducial, detectorCheckerboard, detector)
+	}
+}
+
+const (
+	// fiducialMinPoints is how many depth points must land on a marker to fit its plane.
+	fiducialMinPoints = 10
+	// fiducialInset is how far toward a marker's center its plane is sampled, so that
+	// depth points just past its edge are left out.
+	fiducialInset = 0.9
+	// fiducialSizeTolerance is how far a marker's measured size may be from
+	// marker_size_mm, as a fraction of it.
+	fiducialSizeTolerance = 0.2
+)
+
+// detectFiducials finds markers in the camera's color image and locates each one with the
+// point cloud: the depth points that project onto a marker give its plane, and the rays
+// through its corners meet that plane at the marker's corners. Results are in the camera
+// frame, in ID order.
+func detectFiducials(ctx context.Context, cam camera.Camera, cfg *Config) ([]DetectedObject, error) {
+	props, err := cam.Properties(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get camera properties: %w", err)
+	}
+	if props.IntrinsicParams == nil {
+		return nil, fmt.Errorf("fiducial detection needs the camera's intrinsic parameters")
+	}
+	img, err := colorImage(ctx, cam)
+	if err != nil {
+		return nil, err
+	}
+	var markers []imageMarker
+	for _, m := range findMarkers(toGray(img)) {
+		if wantedMarker(cfg.Fiducial.IDs, m.ID) {
+			markers = append(markers, m)
+		}
+	}
+	if len(markers) == 0 {
+		return nil, nil
+	}
+	cloud, err := cam.NextPointCloud(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get point cloud: %w", err)
+	}
+	return locateMarkers(markers, cloud, props.IntrinsicParams, cfg.Fiducial.MarkerSizeMm), nil
+}
+
+// colorImage returns the first of the camera's images that is not a depth map.
+func colorImage(ctx context.Context, cam camera.Camera) (image.Image, error) {
+	images, _, err := cam.Images(ctx, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get images: %w", err)
+	}
+	for _, named := range images {
+		if named.MimeType() == utils.MimeTypeRawDepth {
+			continue
+		}
+		img, err := named.Image(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image %q: %w", named.SourceName, err)
+		}
+		if _, isDepth := img.(*rimage.DepthMap); !isDepth {
+			return img, nil
+		}
+	}
+	return nil, fmt.Errorf("camera returned no color image")
+}
+
+func wantedMarker(ids []int, id int) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, want := range ids {
+		if want == id {
+			return true
+		}
+	}
+	return false
+}
+
+// locateMarkers turns markers found in the image into objects in the point cloud's frame.
+// Markers without enough depth on them, or of the wrong size, are dropped.
+func locateMarkers(
+	markers []imageMarker, cloud pc.PointCloud, intrinsics *transform.PinholeCameraIntrinsics, sizeMm float64,
+) []DetectedObject {
+	insets := make([]quadCorners, len(markers))
+	for i, m := range markers {
+		center := quadCenter(m.Corners)
+		for j, corner := range m.Corners {
+			insets[i][j] = center.Add(corner.Sub(center).Mul(fiducialInset))
+		}
+	}
+	points := make([][]r3.Vector, len(markers))
+	cloud.Iterate(0, 0, func(p r3.Vector, _ pc.Data) bool {
+		if p.Z <= 0 {
+			return true
+		}
+		px := r2.Point{X: p.X/p.Z*intrinsics.Fx + intrinsics.Ppx, Y: p.Y/p.Z*intrinsics.Fy + intrinsics.Ppy}
+		for i := range insets {
+			if insideQuad(insets[i], px) {
+				points[i] = append(points[i], p)
+			}
+		}
+		return true
+	})
+
+	var detected []DetectedObject
+	for i, m := range markers {
+		if obj, ok := markerObject(m, points[i], intrinsics, sizeMm); ok {
+			detected = append(detected, obj)
+		}
+	}
+	return detected
+}
+
+// markerObject locates one marker from the depth points on it. The marker's frame has X
+// toward its right edge, Y toward its top edge and Z out of its face, toward the camera.
+func markerObject(m imageMarker, points []r3.Vector, intrinsics *transform.PinholeCameraIntrinsics, sizeMm float64) (DetectedObject, bool) {
+	if len(points) < fiducialMinPoints {
+		return DetectedObject{}, false
+	}
+	mean := computeVectorStats(points).Mean
+	normal := principalAxes(points, mean)[2]
+	if normal.Dot(mean) > 0 {
+		normal = normal.Mul(-1)
+	}
+
+	// Where the ray through a pixel meets the marker's plane.
+	onPlane := func(px r2.Point) (r3.Vector, bool) {
+		ray := r3.Vector{X: (px.X - intrinsics.Ppx) / intrinsics.Fx, Y: (px.Y - intrinsics.Ppy) / intrinsics.Fy, Z: 1}
+		denom := normal.Dot(ray)
+		if math.Abs(denom) < 1e-6 {
+			return r3.Vector{}, false
+		}
+		t := normal.Dot(mean) / denom
+		return ray.Mul(t), t > 0
+	}
+	var corners [4]r3.Vector
+	for i, px := range m.Corners {
+		p, ok := onPlane(px)
+		if !ok {
+			return DetectedObject{}, false
+		}
+		corners[i] = p
+	}
+	center, ok := onPlane(quadCenter(m.Corners))
+	if !ok {
+		return DetectedObject{}, false
+	}
+
+	side := 0.0
+	for i := range corners {
+		side += vecNorm(corners[(i+1)%4].Sub(corners[i])) / 4
+	}
+	if sizeMm > 0 && math.Abs(side-sizeMm) > fiducialSizeTolerance*sizeMm {
+		return DetectedObject{}, false
+	}
+
+	// Corners run top-left, top-right, bottom-right, bottom-left.
+	x := corners[1].Add(corners[2]).Sub(corners[0]).Sub(corners[3])
+	x = x.Sub(normal.Mul(x.Dot(normal))).Normalize()
+	y := normal.Cross(x)
+	orientation, err := orientationFromAxes(x, y, normal)
+	if err != nil {
+		return DetectedObject{}, false
+	}
+	id := m.ID
+	return DetectedObject{
+		Center:      center,
+		PointCount:  len(points),
+		BoundingBox: computeBoundingBox(corners[:]),
+		OrientedBox: OrientedBox{Center: center, Axes: [3]r3.Vector{x, y, normal}, Dims: [3]float64{side, side, 0}},
+		Orientation: orientation,
+		ID:          &id,
+	}, true
+}
+
+// orientationFromAxes builds the orientation whose X, Y and Z axes are the given
+// orthonormal, right-handed vectors.
+func orientationFromAxes(x, y, z r3.Vector) (spatialmath.Orientation, error) {
+	// The rows of a RotationMatrix are its axes (see rotate).
+	return spatialmath.NewRotationMatrix([]float64{x.X, x.Y, x.Z, y.X, y.Y, y.Z, z.X, z.Y, z.Z})
+}
@@ -0,0 +1,143 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// frameAssociationMm is how far apart, in the detection's frame, two frames' sightings of
+// an object may be and still be the same object. Depth noise moves a centroid by a few
+// millimeters; separate objects are further apart than this.
+const frameAssociationMm = 15.0
+
+// detectFrames detects objects in cfg.Frames consecutive frames from a camera that is
+// not moving, associates the sightings of each object across them and averages them.
+// Objects seen in half the frames or fewer are dropped as spurious. The crop counts are
+// averaged over the frames.
+func detectFrames(
+	ctx context.Context, cam camera.Camera, cfg *Config, cloudPose spatialmath.Pose,
+) ([]DetectedObject, cropStats, error) {
+	var tracks [][]DetectedObject
+	var crop cropStats
+	for frame := 0; frame < cfg.Frames; frame++ {
+		objects, frameCrop, err := detectFrame(ctx, cam, cfg, cloudPose)
+		if err != nil {
+			return nil, cropStats{}, fmt.Errorf("frame %d: %w", frame+1, err)
+		}
+		crop.PointsRemoved += frameCrop.PointsRemoved
+		crop.ClustersRemoved += frameCrop.ClustersRemoved
+		matched := make([]bool, len(tracks))
+		for _, obj := range objects {
+			best, bestDist := -1, math.Inf(1)
+			for i, track := range tracks {
+				if matched[i] || !sameMarker(track[0], obj) {
+					continue
+				}
+				if d := vecNorm(trackCenter(track).Sub(obj.Center)); d <= frameAssociationMm && d < bestDist {
+					best, bestDist = i, d
+				}
+			}
+			if best < 0 {
+				tracks = append(tracks, []DetectedObject{obj})
+				matched = append(matched, true)
+				continue
+			}
+			tracks[best] = append(tracks[best], obj)
+			matched[best] = true
+		}
+	}
+
+	var averaged []DetectedObject
+	for _, track := range tracks {
+		if 2*len(track) > cfg.Frames {
+			averaged = append(averaged, averageSightings(track))
+		}
+	}
+	crop.PointsRemoved = int(math.Round(float64(crop.PointsRemoved) / float64(cfg.Frames)))
+	crop.ClustersRemoved = int(math.Round(float64(crop.ClustersRemoved) / float64(cfg.Frames)))
+	return averaged, crop, nil
+}
+
+func sameMarker(a, b DetectedObject) bool {
+	if a.ID == nil || b.ID == nil {
+		return a.ID == nil && b.ID == nil
+	}
+	return *a.ID == *b.ID
+}
+
+func trackCenter(track []DetectedObject) r3.Vector {
+	centers := make([]r3.Vector, len(track))
+	for i, obj := range track {
+		centers[i] = obj.Center
+	}
+	return computeVectorStats(centers).Mean
+}
+
+// averageSightings merges several sightings of one object. Positions, sizes and heights
+// are averaged; the oriented box's axes and the ground plane are the first sighting's, and
+// each sighting's box dims are matched to those axes before they are averaged.
+func averageSightings(sightings []DetectedObject) DetectedObject {
+	n := float64(len(sightings))
+	out := sightings[0]
+	var centers []r3.Vector
+	var orientations []spatialmath.Orientation
+	var points, height, reprojection float64
+	var boxMin, boxMax, obbCenter r3.Vector
+	var dims [3]float64
+	for _, obj := range sightings {
+		centers = append(centers, obj.Center)
+		if obj.Orientation != nil {
+			orientations = append(orientations, obj.Orientation)
+		}
+		points += float64(obj.PointCount)
+		height += obj.HeightMm
+		reprojection += obj.ReprojectionErrorPx
+		boxMin = boxMin.Add(obj.BoundingBox.Min)
+		boxMax = boxMax.Add(obj.BoundingBox.Max)
+		obbCenter = obbCenter.Add(obj.OrientedBox.Center)
+		for i, d := range alignedDims(out.OrientedBox, obj.OrientedBox) {
+			dims[i] += d
+		}
+	}
+	stats := computeVectorStats(centers)
+	out.Center = stats.Mean
+	out.Frames = len(sightings)
+	out.CenterStdDevMm = stats.StdDev
+	out.PointCount = int(math.Round(points / n))
+	out.HeightMm = height / n
+	out.ReprojectionErrorPx = reprojection / n
+	out.BoundingBox = BoundingBox{Min: boxMin.Mul(1 / n), Max: boxMax.Mul(1 / n)}
+	out.OrientedBox.Center = obbCenter.Mul(1 / n)
+	for i := range dims {
+		out.OrientedBox.Dims[i] = dims[i] / n
+	}
+	if len(orientations) == len(sightings) {
+		out.Orientation = meanOrientation(orientations)
+	}
+	return out
+}
+
+// alignedDims returns box's dims reordered to ref's axes. Near-equal extents can swap the
+// principal axes between sightings, so each of ref's axes takes the dim of box's unused
+// axis most parallel to it.
+func alignedDims(ref, box OrientedBox) [3]float64 {
+	var dims [3]float64
+	var used [3]bool
+	for i, axis := range ref.Axes {
+		best := -1
+		for j, other := range box.Axes {
+			if !used[j] && (best < 0 || math.Abs(axis.Dot(other)) > math.Abs(axis.Dot(box.Axes[best]))) {
+				best = j
+			}
+		}
+		used[best] = true
+		dims[i] = box.Dims[best]
+	}
+	return dims
+}
@@ -0,0 +1,113 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// Grasp orientation modes. The jaws are assumed to close along the gripper frame's X axis.
+const (
+	graspKeepCurrent   = "keep_current"
+	graspAlignToObject = "align_to_object"
+	graspFixed         = "fixed"
+)
+
+// planGraspOrientation chooses the gripper orientation for the approach, in the detection
+// frame. The gripper points along the approach axis; the mode only decides the rotation
+// about it. In world frame with no table or marker found, keep_current also keeps the
+// gripper's current direction.
+func (s *handEyeTest) planGraspOrientation(ctx context.Context, obj DetectedObject, isWorldFrame bool) *spatialmath.OrientationVectorDegrees {
+	axis := approachAxis(obj, isWorldFrame)
+	base := spatialmath.OrientationVectorDegrees{OX: axis.X, OY: axis.Y, OZ: axis.Z}
+	if isWorldFrame {
+		_, measured := obj.upDirection()
+		gripperPose, err := s.motion.GetPose(ctx, s.cfg.toolFrame(), "world", nil, nil)
+		switch {
+		case err != nil:
+			s.logger.Warnf("Could not get gripper world pose for orientation, using default: %v", err)
+			if !measured {
+				base = spatialmath.OrientationVectorDegrees{OX: 0, OY: 1, OZ: 0, Theta: 180}
+			}
+		case !measured:
+			base = *gripperPose.Pose().Orientation().OrientationVectorDegrees()
+		default:
+			base.Theta = gripperPose.Pose().Orientation().OrientationVectorDegrees().Theta
+		}
+	}
+
+	s.applyGraspMode(&base, obj.OrientedBox)
+	return &base
+}
+
+// applyGraspMode sets the rotation of an approach orientation about its axis according to
+// the grasp orientation mode. The box must be in the same frame as the orientation.
+func (s *handEyeTest) applyGraspMode(ov *spatialmath.OrientationVectorDegrees, box OrientedBox) {
+	switch s.cfg.GraspOrientation {
+	case graspFixed:
+		ov.Theta = s.cfg.GraspThetaDeg
+	case graspAlignToObject:
+		if theta, ok := alignedGraspTheta(*ov, box); ok {
+			ov.Theta = theta
+		} else {
+			s.logger.Warnf("Object has no clear minor axis across the approach direction, keeping current grasp angle")
+		}
+	}
+}
+
+// alignedGraspTheta returns the theta for the given approach orientation vector that lines
+// the gripper's X axis (the jaw closing direction) up with the object's narrowest
+// horizontal extent, i.e. its minor axis once the axis along the approach is discarded.
+func alignedGraspTheta(ov spatialmath.OrientationVectorDegrees, box OrientedBox) (float64, bool) {
+	approach := r3.Vector{X: ov.OX, Y: ov.OY, Z: ov.OZ}.Normalize()
+
+	// Discard the box axis most aligned with the approach (the object's height), then
+	// take the narrower of the remaining two.
+	vertical := 0
+	for i, axis := range box.Axes {
+		if math.Abs(axis.Dot(approach)) > math.Abs(box.Axes[vertical].Dot(approach)) {
+			vertical = i
+		}
+	}
+	minor := -1
+	for i := range box.Axes {
+		if i != vertical && (minor < 0 || box.Dims[i] < box.Dims[minor]) {
+			minor = i
+		}
+	}
+	m := box.Axes[minor]
+	m = m.Sub(approach.Mul(m.Dot(approach)))
+	if m.Norm() < 1e-6 {
+		return 0, false
+	}
+	m = m.Normalize()
+
+	// The gripper X axis sweeps through x0 (theta=0) and x90 (theta=90) as theta grows,
+	// whichever handedness the orientation vector convention uses.
+	x0 := rotate(&spatialmath.OrientationVectorDegrees{OX: ov.OX, OY: ov.OY, OZ: ov.OZ}, r3.Vector{X: 1})
+	x90 := rotate(&spatialmath.OrientationVectorDegrees{OX: ov.OX, OY: ov.OY, OZ: ov.OZ, Theta: 90}, r3.Vector{X: 1})
+	theta := math.Atan2(m.Dot(x90), m.Dot(x0)) * 180 / math.Pi
+
+	// Parallel jaws are symmetric, so pick the equivalent angle closest to the current one.
+	for theta-ov.Theta > 90 {
+		theta -= 180
+	}
+	for theta-ov.Theta <= -90 {
+		theta += 180
+	}
+	return theta, true
+}
+
+func validateGraspOrientation(mode string) error {
+	switch mode {
+	case "", graspKeepCurrent, graspAlignToObject, graspFixed:
+		return nil
+	default:
+		return fmt.Errorf("grasp_orientation must be %q, %q or %q, got %q",
+			graspKeepCurrent, graspAlignToObject, graspFixed, mode)
+	}
+}
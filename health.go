@@ -0,0 +1,162 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	generic "go.viam.com/rdk/services/generic"
+)
+
+// calibrationHealth is a sensor whose readings summarize the latest results of a
+// calibration-tester service, so data capture and dashboards can track calibration
+// health without anyone calling DoCommand.
+type calibrationHealth struct {
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	name   resource.Name
+	logger logging.Logger
+	tester resource.Resource
+	window int
+}
+
+func newCalibrationHealth(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (sensor.Sensor, error) {
+	conf, err := resource.NativeConfig[*HealthConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	return NewCalibrationHealth(deps, rawConf.ResourceName(), conf, logger)
+}
+
+func NewCalibrationHealth(deps resource.Dependencies, name resource.Name, cfg *HealthConfig, logger logging.Logger) (sensor.Sensor, error) {
+	cfg.applyDefaults()
+	tester, err := generic.FromProvider(deps, cfg.Tester)
+	if err != nil {
+		return nil, fmt.Errorf("getting tester %q: %w", cfg.Tester, err)
+	}
+	return &calibrationHealth{
+		name:   name,
+		logger: logger,
+		tester: tester,
+		window: cfg.Window,
+	}, nil
+}
+
+func (h *calibrationHealth) Name() resource.Name {
+	return h.name
+}
+
+// Readings asks the tester for its health summary.
+func (h *calibrationHealth) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	return h.tester.DoCommand(ctx, map[string]interface{}{"command": "health", "window": float64(h.window)})
+}
+
+func (h *calibrationHealth) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("calibration-health has no commands; use Readings")
+}
+
+// healthCache holds what the health readings need from a history file, so sensor polls
+// do not re-parse the whole file. It is reloaded when the file's size or modification
+// time changes, or after the service appends to it.
+type healthCache struct {
+	mu      sync.Mutex
+	valid   bool
+	path    string
+	size    int64
+	modTime time.Time
+	// successes holds each pick's success, oldest first, and latest the most recent pick.
+	successes []bool
+	latest    historyEntry
+}
+
+// invalidate makes the next readings reload the history file.
+func (c *healthCache) invalidate() {
+	c.mu.Lock()
+	c.valid = false
+	c.mu.Unlock()
+}
+
+// load re-parses the history file if it changed since it was last read. Sweeps are skipped.
+func (c *healthCache) load(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		c.valid, c.path, c.successes = true, path, nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if c.valid && c.path == path && c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+		return nil
+	}
+	entries, err := readHistory(path, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	c.successes = nil
+	for _, entry := range entries {
+		if _, isPick := entry.Result["is_holding"]; isPick {
+			success, _ := entry.Result["success"].(bool)
+			c.successes = append(c.successes, success)
+			c.latest = entry
+		}
+	}
+	c.valid, c.path, c.size, c.modTime = true, path, info.Size(), info.ModTime()
+	return nil
+}
+
+// readings reports the latest pick in the history file (offsets, success and when it
+// ran) and the success rate over the last window picks.
+func (c *healthCache) readings(path string, window int) (map[string]interface{}, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no history file configured (set history_file)")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(path); err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	picks := c.successes
+	if len(picks) == 0 {
+		return map[string]interface{}{"picks": 0}, nil
+	}
+
+	latest := c.latest
+	if window > 0 && len(picks) > window {
+		picks = picks[len(picks)-window:]
+	}
+	successes := 0
+	for _, success := range picks {
+		if success {
+			successes++
+		}
+	}
+
+	success, _ := latest.Result["success"].(bool)
+	readings := map[string]interface{}{
+		"picks":        len(picks),
+		"success_rate": float64(successes) / float64(len(picks)),
+		"success":      success,
+		"command":      latest.Command,
+		"timestamp":    latest.Time.Format(time.RFC3339),
+		"age_s":        time.Since(latest.Time).Seconds(),
+	}
+	if v, ok := offsetTotal(latest.Result, "world_frame_offset_mm"); ok {
+		readings["world_frame_offset_mm"] = v
+	}
+	if v, ok := offsetTotal(latest.Result, "approach_offset_mm"); ok {
+		readings["approach_offset_mm"] = v
+	}
+	if place, ok := latest.Result["place"].(map[string]interface{}); ok {
+		if v, ok := offsetTotal(place, "place_offset_mm"); ok {
+			readings["place_offset_mm"] = v
+		}
+	}
+	return readings, nil
+}
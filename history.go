@@ -0,0 +1,248 @@
+package handeyetest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// historyEntry is one line of the JSON-lines history file.
+type historyEntry struct {
+	Time    time.Time              `json:"time"`
+	Command string                 `json:"command"`
+	Result  map[string]interface{} `json:"result"`
+}
+
+// appendHistory appends an entry to the history file, creating it if needed.
+func appendHistory(path string, entry historyEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readHistory returns the entries in [since, until). Zero times leave that side open.
+func readHistory(path string, since, until time.Time) ([]historyEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, lineNum, err)
+		}
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !entry.Time.Before(until) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// parseHistoryTime accepts RFC3339 timestamps or plain dates. A plain date used as an
+// upper bound covers the whole day.
+func parseHistoryTime(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339 or YYYY-MM-DD", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// offsetTotal reads result[key]["total"] from a result map, as stored by pickResult.toMap.
+func offsetTotal(result map[string]interface{}, key string) (float64, bool) {
+	offset, ok := result[key].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	total, ok := offset["total"].(float64)
+	return total, ok
+}
+
+// historyBucket collects the metrics of one group of history entries.
+type historyBucket struct {
+	picks          int
+	successes      int
+	worldOffset    scalarSeries
+	approachOffset scalarSeries
+	sweepStdDev    scalarSeries
+}
+
+func (b *historyBucket) add(entry historyEntry) {
+	if v, ok := offsetTotal(entry.Result, "world_frame_offset_mm"); ok {
+		b.worldOffset = append(b.worldOffset, v)
+	}
+	if v, ok := offsetTotal(entry.Result, "approach_offset_mm"); ok {
+		b.approachOffset = append(b.approachOffset, v)
+	}
+	if pos, ok := entry.Result["world_position"].(map[string]interface{}); ok {
+		if v, ok := offsetTotal(pos, "std_dev_mm"); ok {
+			b.sweepStdDev = append(b.sweepStdDev, v)
+		}
+	}
+	if _, isPick := entry.Result["is_holding"]; isPick {
+		b.picks++
+		if success, _ := entry.Result["success"].(bool); success {
+			b.successes++
+		}
+	}
+}
+
+func (b *historyBucket) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"picks":                 b.picks,
+		"world_frame_offset_mm": b.worldOffset.toMap(),
+		"approach_offset_mm":    b.approachOffset.toMap(),
+		"sweep_std_dev_mm":      b.sweepStdDev.toMap(),
+	}
+	if b.picks > 0 {
+		m["success_rate"] = float64(b.successes) / float64(b.picks)
+	}
+	return m
+}
+
+// summarizeHistory reports overall offset statistics, a per-day breakdown, and the
+// linear drift of the world-frame offset over time.
+func summarizeHistory(entries []historyEntry) map[string]interface{} {
+	overall := &historyBucket{}
+	days := map[string]*historyBucket{}
+	var dayKeys []string
+	var xs, ys []float64
+
+	for _, entry := range entries {
+		overall.add(entry)
+		day := entry.Time.Local().Format("2006-01-02")
+		if _, ok := days[day]; !ok {
+			days[day] = &historyBucket{}
+			dayKeys = append(dayKeys, day)
+		}
+		days[day].add(entry)
+		if v, ok := offsetTotal(entry.Result, "world_frame_offset_mm"); ok {
+			xs = append(xs, entry.Time.Sub(entries[0].Time).Hours()/24)
+			ys = append(ys, v)
+		}
+	}
+
+	sort.Strings(dayKeys)
+	daily := make([]interface{}, len(dayKeys))
+	for i, day := range dayKeys {
+		m := days[day].toMap()
+		m["date"] = day
+		daily[i] = m
+	}
+
+	summary := overall.toMap()
+	summary["entries"] = len(entries)
+	summary["daily"] = daily
+	if slope, ok := linearSlope(xs, ys); ok {
+		summary["world_offset_trend_mm_per_day"] = slope
+	}
+	return summary
+}
+
+// linearSlope fits y = a + b*x by least squares and returns b.
+func linearSlope(xs, ys []float64) (float64, bool) {
+	if len(xs) < 2 {
+		return 0, false
+	}
+	var xMean, yMean float64
+	for i := range xs {
+		xMean += xs[i]
+		yMean += ys[i]
+	}
+	xMean /= float64(len(xs))
+	yMean /= float64(len(ys))
+	var num, den float64
+	for i := range xs {
+		num += (xs[i] - xMean) * (ys[i] - yMean)
+		den += (xs[i] - xMean) * (xs[i] - xMean)
+	}
+	if den == 0 {
+		return 0, false
+	}
+	return num / den, true
+}
+
+// queryHistory reads the history file and summarizes the entries in the given range.
+func queryHistory(path, sinceStr, untilStr string, includeEntries bool) (map[string]interface{}, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no history file configured (set history_file)")
+	}
+	since, err := parseHistoryTime(sinceStr, false)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseHistoryTime(untilStr, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readHistory(path, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+
+	result := map[string]interface{}{
+		"history_file": path,
+		"summary":      summarizeHistory(entries),
+	}
+	if includeEntries {
+		list := make([]interface{}, len(entries))
+		for i, e := range entries {
+			list[i] = map[string]interface{}{
+				"time":    e.Time.Format(time.RFC3339),
+				"command": e.Command,
+				"result":  e.Result,
+			}
+		}
+		result["entries"] = list
+	}
+	return result, nil
+}
+
+// recordHistory appends a pick or sweep result to the configured history file.
+func (s *handEyeTest) recordHistory(command string, result map[string]interface{}) {
+	if s.cfg.HistoryFile == "" || result == nil {
+		return
+	}
+	entry := historyEntry{Time: time.Now(), Command: command, Result: result}
+	if err := appendHistory(s.cfg.HistoryFile, entry); err != nil {
+		s.logger.Warnf("Could not append to history file %s: %v", s.cfg.HistoryFile, err)
+	}
+	s.health.invalidate()
+}
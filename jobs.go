@@ -0,0 +1,225 @@
+package handeyetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+
+	// maxFinishedJobs bounds how many completed jobs are kept for status queries.
+	maxFinishedJobs = 20
+)
+
+// job tracks a long-running command executing in the background.
+type job struct {
+	ID       string
+	Command  string
+	State    string
+	Started  time.Time
+	Finished time.Time
+	Steps    []string
+	Result   map[string]interface{}
+	Err      error
+
+	cancel context.CancelFunc
+}
+
+func (j *job) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"job_id":          j.ID,
+		"command":         j.Command,
+		"state":           j.State,
+		"started":         j.Started.Format(time.RFC3339),
+		"steps_completed": append([]string{}, j.Steps...),
+	}
+	if len(j.Steps) > 0 {
+		m["current_step"] = j.Steps[len(j.Steps)-1]
+	}
+	if j.State == jobRunning {
+		m["elapsed_s"] = time.Since(j.Started).Seconds()
+		return m
+	}
+	m["finished"] = j.Finished.Format(time.RFC3339)
+	m["elapsed_s"] = j.Finished.Sub(j.Started).Seconds()
+	if j.Result != nil {
+		m["result"] = j.Result
+	}
+	if j.Err != nil {
+		m["error"] = j.Err.Error()
+	}
+	return m
+}
+
+type jobKey struct{}
+
+// operation is the motion-producing job that currently owns the arm and gripper, or a
+// detect that will keep its result, which has no JobID.
+type operation struct {
+	Name    string
+	JobID   string
+	Started time.Time
+}
+
+func (o *operation) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"name":    o.Name,
+		"job_id":  o.JobID,
+		"started": o.Started.Format(time.RFC3339),
+	}
+}
+
+// busyError is returned when a command needs the arm while another operation holds it.
+type busyError struct {
+	op operation
+}
+
+func (e *busyError) Error() string {
+	if e.op.JobID == "" {
+		return fmt.Sprintf("busy: %s running since %s", e.op.Name, e.op.Started.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("busy: %s (job %s) running since %s",
+		e.op.Name, e.op.JobID, e.op.Started.Format(time.RFC3339))
+}
+
+// checkIdleLocked returns a busyError if a motion-producing operation is running. Requires s.mu.
+func (s *handEyeTest) checkIdleLocked() error {
+	if s.activeOp != nil {
+		return &busyError{op: *s.activeOp}
+	}
+	return nil
+}
+
+// startJob runs fn in the background, bound to the service's lifetime, and returns the
+// new job's ID immediately. Progress is reported via reportProgress on the job's context.
+// Jobs drive the arm, so only one may run at a time; starting another returns a busyError.
+func (s *handEyeTest) startJob(command string, fn func(ctx context.Context) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	s.mu.Lock()
+	if err := s.checkIdleLocked(); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.jobCounter++
+	j := &job{
+		ID:      fmt.Sprintf("%s-%d", command, s.jobCounter),
+		Command: command,
+		State:   jobRunning,
+		Started: time.Now(),
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(s.cancelCtx, jobKey{}, j))
+	j.cancel = cancel
+	s.activeOp = &operation{Name: command, JobID: j.ID, Started: j.Started}
+	s.jobs[j.ID] = j
+	s.jobOrder = append(s.jobOrder, j.ID)
+	s.pruneJobsLocked()
+	s.mu.Unlock()
+
+	s.logger.Infof("Started job %s", j.ID)
+	go func() {
+		defer cancel()
+		result, err := fn(ctx)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.activeOp = nil
+		j.Finished = time.Now()
+		j.Result = result
+		j.Err = err
+		switch {
+		case err == nil:
+			j.State = jobSucceeded
+		case errors.Is(err, context.Canceled) || ctx.Err() != nil:
+			j.State = jobCancelled
+		default:
+			j.State = jobFailed
+		}
+		s.logger.Infof("Job %s %s", j.ID, j.State)
+	}()
+
+	return map[string]interface{}{"job_id": j.ID, "state": jobRunning}, nil
+}
+
+// pruneJobsLocked drops the oldest finished jobs beyond maxFinishedJobs. Requires s.mu.
+func (s *handEyeTest) pruneJobsLocked() {
+	finished := 0
+	for _, id := range s.jobOrder {
+		if s.jobs[id].State != jobRunning {
+			finished++
+		}
+	}
+	kept := s.jobOrder[:0]
+	for _, id := range s.jobOrder {
+		if finished > maxFinishedJobs && s.jobs[id].State != jobRunning {
+			delete(s.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.jobOrder = kept
+}
+
+// reportProgress records a completed step on the job running under ctx, if any.
+func (s *handEyeTest) reportProgress(ctx context.Context, step string) {
+	j, ok := ctx.Value(jobKey{}).(*job)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	j.Steps = append(j.Steps, step)
+	s.mu.Unlock()
+}
+
+// lookupJobLocked returns the job with the given ID, or the most recent job if id is empty.
+// Requires s.mu.
+func (s *handEyeTest) lookupJobLocked(id string) (*job, error) {
+	if id == "" {
+		if len(s.jobOrder) == 0 {
+			return nil, nil
+		}
+		id = s.jobOrder[len(s.jobOrder)-1]
+	}
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown job %q", id)
+	}
+	return j, nil
+}
+
+// handleCancel cancels a running job (the most recent one by default), then stops the
+// arm and gripper so nothing keeps moving after the job's context is gone.
+func (s *handEyeTest) handleCancel(ctx context.Context, jobID string) (map[string]interface{}, error) {
+	s.mu.Lock()
+	j, err := s.lookupJobLocked(jobID)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if j == nil || j.State != jobRunning {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("no running job to cancel")
+	}
+	j.cancel()
+	s.mu.Unlock()
+
+	s.logger.Infof("Cancelling job %s, stopping arm and gripper", j.ID)
+	var stopErrs []error
+	if err := s.arm.Stop(ctx, nil); err != nil {
+		stopErrs = append(stopErrs, fmt.Errorf("failed to stop arm: %w", err))
+	}
+	if s.gripper != nil {
+		if err := s.gripper.Stop(ctx, nil); err != nil {
+			stopErrs = append(stopErrs, fmt.Errorf("failed to stop gripper: %w", err))
+		}
+	}
+	if err := errors.Join(stopErrs...); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"job_id": j.ID, "cancelled": true}, nil
+}
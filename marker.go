@@ -0,0 +1,487 @@
+package handeyetest
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/golang/geo/r2"
+)
+
+// Markers are from the original ArUco dictionary (DICT_ARUCO_ORIGINAL in OpenCV): a black
+// border one cell wide around 5x5 data cells, 7x7 cells in all. Each row of data carries
+// two bits of the ID, most significant row first, as one of four 5-bit words (1 = white).
+const (
+	markerCells     = 7
+	markerDataCells = 5
+	markerMaxID     = 1023
+)
+
+var arucoRowWords = [4]uint8{0x10, 0x17, 0x09, 0x0e}
+
+const (
+	// adaptiveThresholdC is how much darker than its neighborhood a pixel must be to count
+	// as part of a marker's border.
+	adaptiveThresholdC = 7
+	// minMarkerSidePx is the smallest marker side, in pixels, that can still be decoded
+	// (three pixels per cell).
+	minMarkerSidePx = 3 * markerCells
+	// minQuadFill is how much of a dark region's convex hull its fitted quadrilateral must
+	// cover; squares fill it completely, blobs and circles do not.
+	minQuadFill = 0.85
+	// minMarkerContrast is the smallest gray-level difference between a marker's black and
+	// white cells.
+	minMarkerContrast = 30
+)
+
+// quadCorners are a quadrilateral's corners in pixels, clockwise on screen. For a
+// decoded marker they start at the marker's top-left corner.
+type quadCorners [4]r2.Point
+
+// imageMarker is a marker found in an image.
+type imageMarker struct {
+	ID      int
+	Corners quadCorners
+}
+
+// toGray copies an image into a grayscale image whose bounds start at the origin.
+func toGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	if g, ok := img.(*image.Gray); ok {
+		// Rows may be padded, or belong to a larger image for a SubImage, so copy each.
+		for y := 0; y < b.Dy(); y++ {
+			start := g.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(gray.Pix[y*gray.Stride:(y+1)*gray.Stride], g.Pix[start:start+b.Dx()])
+		}
+		return gray
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			gray.SetGray(x, y, color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray))
+		}
+	}
+	return gray
+}
+
+// findMarkers finds and decodes every marker in a grayscale image.
+func findMarkers(gray *image.Gray) []imageMarker {
+	var markers []imageMarker
+	dark := adaptiveThreshold(gray)
+	for _, quad := range findQuads(dark, gray.Rect.Dx(), gray.Rect.Dy(), minMarkerSidePx) {
+		if m, ok := decodeMarker(gray, quad); ok {
+			markers = append(markers, m)
+		}
+	}
+	sort.Slice(markers, func(i, j int) bool { return markers[i].ID < markers[j].ID })
+	return markers
+}
+
+// adaptiveThreshold marks pixels noticeably darker than the mean of the window around
+// them. A window a few marker cells wide keeps a border dark under uneven lighting.
+func adaptiveThreshold(gray *image.Gray) []bool {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	integral := make([]int64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row int64
+		for x := 0; x < w; x++ {
+			row += int64(gray.Pix[y*gray.Stride+x])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+		}
+	}
+
+	r := max(3, min(w, h)/40)
+	dark := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		y0, y1 := max(0, y-r), min(h, y+r+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(0, x-r), min(w, x+r+1)
+			sum := integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0]
+			n := int64((y1 - y0) * (x1 - x0))
+			dark[y*w+x] = (int64(gray.Pix[y*gray.Stride+x])+adaptiveThresholdC)*n < sum
+		}
+	}
+	return dark
+}
+
+// erode clears dark pixels with a light 4-neighbor, separating dark regions that only
+// touch at a corner.
+func erode(dark []bool, w, h int) []bool {
+	out := make([]bool, len(dark))
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			out[i] = dark[i] && dark[i-1] && dark[i+1] && dark[i-w] && dark[i+w]
+		}
+	}
+	return out
+}
+
+// findQuads returns the dark regions of a w x h thresholded image shaped like a
+// quadrilateral with sides of at least minSidePx, with corners refined to sub-pixel
+// precision along the region's edges.
+func findQuads(dark []bool, w, h int, minSidePx float64) []quadCorners {
+	label := make([]int32, w*h)
+	var quads []quadCorners
+	var next int32
+
+	for start := range dark {
+		if !dark[start] || label[start] != 0 {
+			continue
+		}
+		next++
+		// Flood fill the 8-connected region, noting whether it touches the image edge.
+		region := []int{start}
+		label[start] = next
+		touchesEdge := false
+		for i := 0; i < len(region); i++ {
+			x, y := region[i]%w, region[i]/w
+			if x == 0 || y == 0 || x == w-1 || y == h-1 {
+				touchesEdge = true
+			}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					if n := ny*w + nx; dark[n] && label[n] == 0 {
+						label[n] = next
+						region = append(region, n)
+					}
+				}
+			}
+		}
+		if touchesEdge || float64(len(region)) < 4*minSidePx {
+			continue
+		}
+
+		// Boundary pixels have a 4-neighbor outside the region.
+		var boundary []r2.Point
+		for _, i := range region {
+			x, y := i%w, i/w
+			if label[i-1] != next || label[i+1] != next || label[i-w] != next || label[i+w] != next {
+				boundary = append(boundary, r2.Point{X: float64(x), Y: float64(y)})
+			}
+		}
+		if quad, ok := fitQuad(boundary, minSidePx); ok {
+			quads = append(quads, quad)
+		}
+	}
+	return quads
+}
+
+// fitQuad fits a quadrilateral to a region's boundary with hullQuad, then refits each
+// side to the boundary pixels along it.
+func fitQuad(boundary []r2.Point, minSidePx float64) (quadCorners, bool) {
+	hull := convexHull(boundary)
+	quad, ok := hullQuad(hull)
+	if !ok {
+		return quad, false
+	}
+	for i := range quad {
+		if quad[(i+1)%4].Sub(quad[i]).Norm() < minSidePx {
+			return quad, false
+		}
+	}
+	if polygonArea(quad[:]) < minQuadFill*polygonArea(hull) {
+		return quad, false
+	}
+	return refineCorners(quad, boundary), true
+}
+
+// hullQuad picks the four corners of a convex hull: opposite corners are the farthest
+// pair of hull points, the other two the hull points farthest to either side of that
+// diagonal.
+func hullQuad(hull []r2.Point) (quadCorners, bool) {
+	var quad quadCorners
+	if len(hull) < 4 {
+		return quad, false
+	}
+	a, c := 0, 0
+	for i := range hull {
+		for j := i + 1; j < len(hull); j++ {
+			if hull[i].Sub(hull[j]).Norm() > hull[a].Sub(hull[c]).Norm() {
+				a, c = i, j
+			}
+		}
+	}
+	diag := hull[c].Sub(hull[a])
+	b, d := -1, -1
+	var bDist, dDist float64
+	for i, p := range hull {
+		side := diag.Cross(p.Sub(hull[a]))
+		if side > bDist {
+			b, bDist = i, side
+		}
+		if -side > dDist {
+			d, dDist = i, -side
+		}
+	}
+	if b < 0 || d < 0 {
+		return quad, false
+	}
+	// With Y pointing down, a positive cross product turns clockwise on screen, so going
+	// clockwise from a, the corner on the negative side of the diagonal comes first.
+	return quadCorners{hull[a], hull[d], hull[c], hull[b]}, true
+}
+
+// refineCorners fits a line to the boundary pixels along the middle of each side, moves it
+// out half a pixel to the region's edge, and intersects neighboring sides. The coarse
+// corners are kept if a side has too few pixels to fit.
+func refineCorners(quad quadCorners, boundary []r2.Point) quadCorners {
+	center := quadCenter(quad)
+	var lines [4][2]r2.Point // a point on each side and its direction
+	for i := range quad {
+		p, q := quad[i], quad[(i+1)%4]
+		dir := q.Sub(p).Normalize()
+		length := q.Sub(p).Norm()
+		var pts []r2.Point
+		for _, b := range boundary {
+			t := b.Sub(p).Dot(dir)
+			if t > 0.15*length && t < 0.85*length && math.Abs(dir.Cross(b.Sub(p))) < 1.5 {
+				pts = append(pts, b)
+			}
+		}
+		if len(pts) < 4 {
+			return quad
+		}
+		mean, lineDir := fitLine(pts)
+		normal := lineDir.Ortho()
+		if normal.Dot(mean.Sub(center)) < 0 {
+			normal = normal.Mul(-1)
+		}
+		lines[i] = [2]r2.Point{mean.Add(normal.Mul(0.5)), lineDir}
+	}
+	var refined quadCorners
+	for i := range quad {
+		prev := lines[(i+3)%4]
+		corner, ok := intersectLines(prev[0], prev[1], lines[i][0], lines[i][1])
+		if !ok || corner.Sub(quad[i]).Norm() > 3 {
+			return quad
+		}
+		refined[i] = corner
+	}
+	return refined
+}
+
+// decodeMarker samples the cells of a candidate quadrilateral and reads its ID. The border
+// must be black; the data is tried in all four rotations, correcting up to one wrong bit
+// per row, and must match in exactly one. The corners are returned starting at the
+// marker's top-left.
+func decodeMarker(gray *image.Gray, quad quadCorners) (imageMarker, bool) {
+	cells := sampleCells(gray, quad)
+	lo, hi := 255.0, 0.0
+	for _, row := range cells {
+		for _, v := range row {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	if hi-lo < minMarkerContrast {
+		return imageMarker{}, false
+	}
+	threshold := (lo + hi) / 2
+	for i := 0; i < markerCells; i++ {
+		for _, v := range []float64{cells[0][i], cells[markerCells-1][i], cells[i][0], cells[i][markerCells-1]} {
+			if v > threshold {
+				return imageMarker{}, false
+			}
+		}
+	}
+
+	best, bestErrors, tied := -1, math.MaxInt, false
+	var bestID int
+	for rot := 0; rot < 4; rot++ {
+		id, errors, ok := readMarkerID(cells, threshold, rot)
+		if !ok {
+			continue
+		}
+		switch {
+		case errors < bestErrors:
+			best, bestErrors, bestID, tied = rot, errors, id, false
+		case errors == bestErrors:
+			tied = true
+		}
+	}
+	if best < 0 || tied {
+		return imageMarker{}, false
+	}
+	var corners quadCorners
+	for i := range corners {
+		corners[i] = quad[(i+best)%4]
+	}
+	return imageMarker{ID: bestID, Corners: corners}, true
+}
+
+// readMarkerID reads the data cells with the marker's top-left at quad corner rot,
+// returning the ID and the number of corrected bits.
+func readMarkerID(cells [markerCells][markerCells]float64, threshold float64, rot int) (int, int, bool) {
+	id, errors := 0, 0
+	for r := 0; r < markerDataCells; r++ {
+		var word uint8
+		for c := 0; c < markerDataCells; c++ {
+			// Rotate (r, c) in the marker into the grid sampled from corner 0.
+			gr, gc := r+1, c+1
+			for k := 0; k < rot; k++ {
+				gr, gc = gc, markerCells-1-gr
+			}
+			word <<= 1
+			if cells[gr][gc] > threshold {
+				word |= 1
+			}
+		}
+		bits, dist := 0, math.MaxInt
+		for i, w := range arucoRowWords {
+			if d := bitCount(word ^ w); d < dist {
+				bits, dist = i, d
+			}
+		}
+		if dist > 1 {
+			return 0, 0, false
+		}
+		id = id<<2 | bits
+		errors += dist
+	}
+	return id, errors, true
+}
+
+// sampleCells averages a 3x3 grid of pixels around the center of each marker cell, mapping
+// cell coordinates into the image through the quadrilateral.
+func sampleCells(gray *image.Gray, quad quadCorners) [markerCells][markerCells]float64 {
+	var cells [markerCells][markerCells]float64
+	for r := 0; r < markerCells; r++ {
+		for c := 0; c < markerCells; c++ {
+			sum, n := 0.0, 0
+			for _, dv := range []float64{-0.2, 0, 0.2} {
+				for _, du := range []float64{-0.2, 0, 0.2} {
+					if v, ok := grayAt(gray, squareToQuad(quad, (float64(c)+0.5+du)/markerCells, (float64(r)+0.5+dv)/markerCells)); ok {
+						sum += v
+						n++
+					}
+				}
+			}
+			if n > 0 {
+				cells[r][c] = sum / float64(n)
+			}
+		}
+	}
+	return cells
+}
+
+// grayAt returns the gray level of the pixel nearest a point, false outside the image.
+func grayAt(gray *image.Gray, p r2.Point) (float64, bool) {
+	x, y := int(math.Round(p.X)), int(math.Round(p.Y))
+	if x < 0 || y < 0 || x >= gray.Rect.Dx() || y >= gray.Rect.Dy() {
+		return 0, false
+	}
+	return float64(gray.Pix[y*gray.Stride+x]), true
+}
+
+// squareToQuad maps (u, v) in the unit square onto the quadrilateral through the
+// projective transform taking (0,0), (1,0), (1,1), (0,1) to its corners.
+func squareToQuad(quad quadCorners, u, v float64) r2.Point {
+	p0, p1, p2, p3 := quad[0], quad[1], quad[2], quad[3]
+	d1, d2 := p1.Sub(p2), p3.Sub(p2)
+	d3 := p0.Sub(p1).Add(p2).Sub(p3)
+	det := d1.Cross(d2)
+	var g, h float64
+	if det != 0 {
+		g = d3.Cross(d2) / det
+		h = d1.Cross(d3) / det
+	}
+	x := (p1.X-p0.X+g*p1.X)*u + (p3.X-p0.X+h*p3.X)*v + p0.X
+	y := (p1.Y-p0.Y+g*p1.Y)*u + (p3.Y-p0.Y+h*p3.Y)*v + p0.Y
+	z := g*u + h*v + 1
+	return r2.Point{X: x / z, Y: y / z}
+}
+
+// quadCenter is where the diagonals cross: the image of the marker's center, even in
+// perspective.
+func quadCenter(quad quadCorners) r2.Point {
+	return squareToQuad(quad, 0.5, 0.5)
+}
+
+// insideQuad reports whether a point lies inside a quadrilateral with clockwise corners.
+func insideQuad(quad quadCorners, p r2.Point) bool {
+	for i := range quad {
+		if quad[(i+1)%4].Sub(quad[i]).Cross(p.Sub(quad[i])) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// convexHull returns the convex hull of the points, clockwise on screen (monotone chain).
+func convexHull(points []r2.Point) []r2.Point {
+	pts := append([]r2.Point{}, points...)
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i].X != pts[j].X {
+			return pts[i].X < pts[j].X
+		}
+		return pts[i].Y < pts[j].Y
+	})
+	if len(pts) < 3 {
+		return pts
+	}
+	hull := make([]r2.Point, 0, 2*len(pts))
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, p := range pts {
+			for len(hull) >= start+2 && hull[len(hull)-1].Sub(hull[len(hull)-2]).Cross(p.Sub(hull[len(hull)-2])) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	return hull
+}
+
+// polygonArea is the area of a simple polygon (shoelace formula).
+func polygonArea(poly []r2.Point) float64 {
+	area := 0.0
+	for i := range poly {
+		area += poly[i].Cross(poly[(i+1)%len(poly)])
+	}
+	return math.Abs(area) / 2
+}
+
+// fitLine returns the mean of the points and the direction of the line through them.
+func fitLine(pts []r2.Point) (r2.Point, r2.Point) {
+	var mean r2.Point
+	for _, p := range pts {
+		mean = mean.Add(p)
+	}
+	mean = mean.Mul(1 / float64(len(pts)))
+	var sxx, sxy, syy float64
+	for _, p := range pts {
+		d := p.Sub(mean)
+		sxx += d.X * d.X
+		sxy += d.X * d.Y
+		syy += d.Y * d.Y
+	}
+	angle := math.Atan2(2*sxy, sxx-syy) / 2
+	return mean, r2.Point{X: math.Cos(angle), Y: math.Sin(angle)}
+}
+
+// intersectLines intersects two lines given as a point and a direction.
+func intersectLines(p, dp, q, dq r2.Point) (r2.Point, bool) {
+	denom := dp.Cross(dq)
+	if math.Abs(denom) < 1e-9 {
+		return r2.Point{}, false
+	}
+	t := q.Sub(p).Cross(dq) / denom
+	return p.Add(dp.Mul(t)), true
+}
+
+func bitCount(b uint8) int {
+	n := 0
+	for ; b != 0; b &= b - 1 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// Camera mounts. An eye-in-hand camera rides on the arm, so moving toward it from the
+// object means moving up; an eye-to-hand camera is fixed in the cell and its view
+// direction says nothing about which way is up.
+const (
+	cameraEyeInHand = "eye_in_hand"
+	cameraEyeToHand = "eye_to_hand"
+)
+
+func validateCameraMount(mount string) error {
+	switch mount {
+	case "", cameraEyeInHand, cameraEyeToHand:
+		return nil
+	default:
+		return fmt.Errorf("camera_mount must be %q or %q, got %q", cameraEyeInHand, cameraEyeToHand, mount)
+	}
+}
+
+// staticApproach plans the approach pose in world for an eye-to-hand camera: the gripper
+// points down the table normal (or into a marker's face) from approach_offset_mm above
+// the object, along world Z if neither was found.
+func (s *handEyeTest) staticApproach(ctx context.Context, obj DetectedObject, detectionFrame string) (spatialmath.Pose, error) {
+	detectionWorld, err := s.frameWorldPose(ctx, detectionFrame)
+	if err != nil {
+		return nil, err
+	}
+	up := r3.Vector{Z: 1}
+	if objUp, ok := obj.upDirection(); ok {
+		up = rotate(detectionWorld.Orientation(), objUp)
+	} else {
+		s.logger.Warnf("No table plane found with the object, approaching along world Z")
+	}
+	objectWorld := spatialmath.Compose(detectionWorld, spatialmath.NewPoseFromPoint(obj.Center)).Point()
+
+	// keep_current keeps the gripper's present rotation about its approach axis.
+	ov := spatialmath.OrientationVectorDegrees{OX: -up.X, OY: -up.Y, OZ: -up.Z}
+	if gripperPose, err := s.motion.GetPose(ctx, s.cfg.toolFrame(), "world", nil, nil); err != nil {
+		s.logger.Warnf("Could not get gripper world pose for orientation, using theta 0: %v", err)
+	} else {
+		ov.Theta = gripperPose.Pose().Orientation().OrientationVectorDegrees().Theta
+	}
+	s.applyGraspMode(&ov, obj.OrientedBox.transform(detectionWorld))
+
+	return spatialmath.NewPose(objectWorld.Add(up.Mul(s.cfg.ApproachOffsetMm)), &ov), nil
+}
+
+// tableUp returns the normal of the table found with the object, pointing up from the
+// table, in world; for a marker, the normal of its face. It is false if the detector
+// measured neither or the detection frame could not be located.
+func (s *handEyeTest) tableUp(ctx context.Context, obj DetectedObject, detectionFrame string) (r3.Vector, bool) {
+	up, ok := obj.upDirection()
+	if !ok {
+		return r3.Vector{}, false
+	}
+	detectionWorld, err := s.frameWorldPose(ctx, detectionFrame)
+	if err != nil {
+		s.logger.Warnf("Could not transform table normal to world frame (non-fatal): %v", err)
+		return r3.Vector{}, false
+	}
+	return rotate(detectionWorld.Orientation(), up), true
+}
+
+// armBaseDirection expresses a world direction in the arm base frame, for straight-line
+// moves through the arm driver. It assumes the base is level with world if it cannot be
+// located.
+func (s *handEyeTest) armBaseDirection(ctx context.Context, dir r3.Vector) r3.Vector {
+	base, err := s.armBasePose(ctx)
+	if err != nil {
+		s.logger.Warnf("Could not locate the arm base, assuming it is level with world: %v", err)
+		return dir
+	}
+	return rotate(spatialmath.PoseInverse(base).Orientation(), dir)
+}
@@ -26,7 +26,7 @@ func (s *handEyeTest) handleMoveTo(ctx context.Context, target r3.Vector, stepSi
 
 	var steps int
 	for steps = 0; steps < maxSteps; steps++ {
-		gripperPose, err := s.motion.GetPose(ctx, s.cfg.Gripper, "world", nil, nil)
+		gripperPose, err := s.motion.GetPose(ctx, s.cfg.toolFrame(), "world", nil, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get gripper pose: %w", err)
 		}
@@ -60,7 +60,7 @@ func (s *handEyeTest) handleMoveTo(ctx context.Context, target r3.Vector, stepSi
 		s.logger.Infof("Step %d: moving to (%.1f, %.1f, %.1f)...", steps, nextPoint.X, nextPoint.Y, nextPoint.Z)
 
 		success, err := s.motion.Move(ctx, motion.MoveReq{
-			ComponentName: s.cfg.Gripper,
+			ComponentName: s.cfg.toolFrame(),
 			Destination:   dest,
 		})
 		if err != nil {
@@ -69,13 +69,14 @@ func (s *handEyeTest) handleMoveTo(ctx context.Context, target r3.Vector, stepSi
 		if !success {
 			return nil, fmt.Errorf("step %d: motion planner could not find path", steps)
 		}
+		s.reportProgress(ctx, fmt.Sprintf("move_step_%d", steps))
 	}
 
 	if steps >= maxSteps {
 		return nil, fmt.Errorf("did not reach target after %d steps", maxSteps)
 	}
 
-	finalPose, err := s.motion.GetPose(ctx, s.cfg.Gripper, "world", nil, nil)
+	finalPose, err := s.motion.GetPose(ctx, s.cfg.toolFrame(), "world", nil, nil)
 	var finalPos r3.Vector
 	if err == nil {
 		finalPos = finalPose.Pose().Point()
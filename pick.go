@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"strings"
+	"time"
 
 	"github.com/golang/geo/r3"
 
@@ -20,12 +22,30 @@ type pickResult struct {
 	ObjectPositionWorldFrame  r3.Vector
 	GripperPositionWorldFrame r3.Vector
 	ApproachOffsetMm          r3.Vector
-	WorldFrameOffsetMm        r3.Vector
-	StepsCompleted            []string
+	// RedetectMatched is whether re-detection from the approach position found the same
+	// object, RedetectMatchDistanceMm how far apart the two sightings are in world frame.
+	RedetectMatched         bool
+	RedetectMatchDistanceMm float64
+	WorldFrameOffsetMm      r3.Vector
+	// DetectionNoiseMm is the per-axis standard deviation of the object's center over the
+	// frames it was averaged from, in the detection frame; DetectionFrames counts them.
+	DetectionNoiseMm r3.Vector
+	DetectionFrames  int
+	GraspYawDeg      float64
+	StepsCompleted   []string
+	StepDurationsMs  map[string]float64
+	Place            *placeResult
+	Verdict          verdict
+
+	stepStarted      time.Time
+	redetected       bool
+	approachMeasured bool
+	// tableUp is the table normal in world, or zero if no table was found.
+	tableUp r3.Vector
 }
 
 func (r *pickResult) toMap() map[string]interface{} {
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		"success":    r.Success,
 		"is_holding": r.IsHolding,
 		"detected_position": map[string]interface{}{
@@ -48,80 +68,206 @@ func (r *pickResult) toMap() map[string]interface{} {
 			"x": r.WorldFrameOffsetMm.X, "y": r.WorldFrameOffsetMm.Y, "z": r.WorldFrameOffsetMm.Z,
 			"total": vecNorm(r.WorldFrameOffsetMm),
 		},
-		"steps_completed": r.StepsCompleted,
+		"grasp_yaw_deg":     r.GraspYawDeg,
+		"steps_completed":   r.StepsCompleted,
+		"step_durations_ms": r.StepDurationsMs,
+		"verdict":           r.Verdict.toMap(),
+	}
+	if r.redetected {
+		m["redetect_matched"] = r.RedetectMatched
+		if r.RedetectMatched {
+			m["redetect_match_distance_mm"] = r.RedetectMatchDistanceMm
+		}
+	}
+	if r.DetectionFrames > 1 {
+		m["detection_noise_mm"] = map[string]interface{}{
+			"x": r.DetectionNoiseMm.X, "y": r.DetectionNoiseMm.Y, "z": r.DetectionNoiseMm.Z,
+			"total": vecNorm(r.DetectionNoiseMm), "frames": r.DetectionFrames, "frame": r.DetectionFrame,
+		}
 	}
+	if r.Place != nil {
+		m["place"] = r.Place.toMap()
+	}
+	return m
+}
+
+// stepDone marks a pick step as completed on the result and on the running job, and
+// records how long it took since the previous step finished.
+func (s *handEyeTest) stepDone(ctx context.Context, result *pickResult, step string) {
+	now := time.Now()
+	if result.StepDurationsMs == nil {
+		result.StepDurationsMs = map[string]float64{}
+	}
+	result.StepDurationsMs[step] = float64(now.Sub(result.stepStarted).Microseconds()) / 1000
+	result.stepStarted = now
+	result.StepsCompleted = append(result.StepsCompleted, step)
+	s.reportProgress(ctx, step)
 }
 
 func vecNorm(v r3.Vector) float64 {
 	return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
 }
 
-func (s *handEyeTest) executePick(ctx context.Context, obj DetectedObject) (map[string]interface{}, error) {
+// approachAxis is the direction the gripper moves toward the object along, in the
+// detection frame: into a marker's face, or down the table normal if segmentation found
+// the table. Otherwise it assumes world Z is up, or in camera frame that the camera looks
+// down at the table.
+func approachAxis(obj DetectedObject, isWorldFrame bool) r3.Vector {
+	if up, ok := obj.upDirection(); ok {
+		return up.Mul(-1)
+	}
+	if isWorldFrame {
+		return r3.Vector{Z: -1}
+	}
+	return r3.Vector{Z: 1}
+}
+
+// approachPoint is the approach position, approach_offset_mm back along the approach
+// axis from the object, in the detection frame.
+func (s *handEyeTest) approachPoint(obj DetectedObject, isWorldFrame bool) r3.Vector {
+	return obj.Center.Sub(approachAxis(obj, isWorldFrame).Mul(s.cfg.ApproachOffsetMm))
+}
+
+// maxRedetectSizeRatio is how many times larger one sighting of an object may be than
+// the other, by the longest side of its oriented box, and still be the same object.
+const maxRedetectSizeRatio = 2.0
+
+// matchRedetection finds the original object among the re-detected ones: the nearest in
+// world frame, within redetect_gate_mm, of a similar size and with the same marker ID if
+// it has one. It returns the match's index and world distance, or -1 if none is plausible.
+func (s *handEyeTest) matchRedetection(
+	ctx context.Context, original DetectedObject, originalWorld r3.Vector, objects []DetectedObject,
+) (int, float64, error) {
+	if originalWorld == (r3.Vector{}) {
+		return -1, 0, fmt.Errorf("the original detection has no world-frame position")
+	}
+	match, bestDist := -1, math.Inf(1)
+	for i, obj := range objects {
+		if (original.ID == nil) != (obj.ID == nil) || (original.ID != nil && *original.ID != *obj.ID) {
+			continue
+		}
+		if !similarSize(original.OrientedBox, obj.OrientedBox) {
+			continue
+		}
+		worldPos, err := s.toWorldFrame(ctx, obj.Center, s.cfg.detectionFrame())
+		if err != nil {
+			return -1, 0, err
+		}
+		if d := vecNorm(worldPos.Sub(originalWorld)); d <= s.cfg.RedetectGateMm && d < bestDist {
+			match, bestDist = i, d
+		}
+	}
+	if match < 0 {
+		return -1, 0, nil
+	}
+	return match, bestDist, nil
+}
+
+// similarSize reports whether two boxes' longest sides are within maxRedetectSizeRatio of
+// each other. Boxes without a size are not compared.
+func similarSize(a, b OrientedBox) bool {
+	la := math.Max(a.Dims[0], math.Max(a.Dims[1], a.Dims[2]))
+	lb := math.Max(b.Dims[0], math.Max(b.Dims[1], b.Dims[2]))
+	if la <= 0 || lb <= 0 {
+		return true
+	}
+	return math.Max(la, lb) <= maxRedetectSizeRatio*math.Min(la, lb)
+}
+
+// pickMotion is the geometry of a pick, worked out from the detection before anything
+// moves: where to approach, and the straight line the grasp descends and the lift rises
+// along. executePick drives it and planPick plans it.
+type pickMotion struct {
+	Approach *referenceframe.PoseInFrame
+	YawDeg   float64
+	// Up is the world direction the lift rises along: the table normal, or the arm base's
+	// Z axis if no table was found. Down is the grasp direction in the arm base frame, for
+	// moves through the arm driver.
+	Up, Down   r3.Vector
+	TableFound bool
+	// GraspDeltaMm is how far the grasp descends past the approach pose.
+	GraspDeltaMm float64
+}
+
+// pickMotion computes the approach pose and grasp direction for an object. Call it while
+// the camera is still where it saw the object.
+func (s *handEyeTest) pickMotion(ctx context.Context, obj DetectedObject, detectionFrame string) (*pickMotion, error) {
+	pm := &pickMotion{GraspDeltaMm: s.cfg.ApproachOffsetMm - s.cfg.GraspDepthOffsetMm}
+	if s.cfg.cameraMount() == cameraEyeToHand {
+		// A static camera says nothing about up, so plan in world instead.
+		approachWorld, err := s.staticApproach(ctx, obj, detectionFrame)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan approach: %w", err)
+		}
+		pm.YawDeg = approachWorld.Orientation().OrientationVectorDegrees().Theta
+		pm.Approach = referenceframe.NewPoseInFrame("world", approachWorld)
+	} else {
+		// Approach in the detection frame, choosing the grasp yaw about the approach axis.
+		isWorldFrame := detectionFrame == "world"
+		orientation := s.planGraspOrientation(ctx, obj, isWorldFrame)
+		pm.YawDeg = orientation.Theta
+		pm.Approach = referenceframe.NewPoseInFrame(detectionFrame,
+			spatialmath.NewPose(s.approachPoint(obj, isWorldFrame), orientation))
+	}
+
+	pm.Up, pm.TableFound = s.tableUp(ctx, obj, detectionFrame)
+	if pm.TableFound {
+		pm.Down = s.armBaseDirection(ctx, pm.Up.Mul(-1))
+	} else {
+		pm.Up = s.armBaseUp(ctx)
+		pm.Down = r3.Vector{Z: -1}
+	}
+	return pm, nil
+}
+
+func (s *handEyeTest) executePick(ctx context.Context, obj DetectedObject, opts pickOptions) (*pickResult, error) {
 	s.mu.Lock()
 	s.currentStatus = "picking"
 	s.mu.Unlock()
 
-	detectionFrame := s.cfg.DetectionFrame
-	if detectionFrame == "" {
-		detectionFrame = s.cfg.Camera
-	}
-	isWorldFrame := detectionFrame == "world"
+	detectionFrame := s.cfg.detectionFrame()
 
 	result := &pickResult{
 		DetectedPosition: obj.Center,
 		DetectionFrame:   detectionFrame,
+		DetectionNoiseMm: obj.CenterStdDevMm,
+		DetectionFrames:  obj.Frames,
+		stepStarted:      time.Now(),
 	}
 
 	s.logger.Infof("Starting pick sequence for object at %s-frame position: (%.1f, %.1f, %.1f)mm",
 		detectionFrame, obj.Center.X, obj.Center.Y, obj.Center.Z)
 
+	// Transform the detection to world and work out the pick's motion now, while the camera
+	// is still where it saw the object (the transform is a no-op in world frame).
+	objectInWorld, err := s.toWorldFrame(ctx, obj.Center, detectionFrame)
+	if err != nil {
+		s.logger.Warnf("Could not transform object to world frame (non-fatal): %v", err)
+	} else {
+		result.ObjectPositionWorldFrame = objectInWorld
+	}
+	pm, err := s.pickMotion(ctx, obj, detectionFrame)
+	if err != nil {
+		return nil, err
+	}
+	if pm.TableFound {
+		result.tableUp = pm.Up
+	}
+	result.GraspYawDeg = pm.YawDeg
+	s.logger.Infof("Grasp yaw: %.1f deg (%s)", result.GraspYawDeg, s.cfg.graspOrientation())
+
 	// Step 1: Open gripper
 	s.logger.Infof("Opening gripper...")
 	if err := s.gripper.Open(ctx, nil); err != nil {
 		return nil, fmt.Errorf("failed to open gripper: %w", err)
 	}
-	result.StepsCompleted = append(result.StepsCompleted, "open_gripper")
+	s.stepDone(ctx, result, "open_gripper")
 
-	// Step 2: Compute approach pose in detection frame
-	var approachPoint r3.Vector
-	if isWorldFrame {
-		// World frame: Z is up, approach is above the object
-		approachPoint = r3.Vector{
-			X: obj.Center.X,
-			Y: obj.Center.Y,
-			Z: obj.Center.Z + s.cfg.ApproachOffsetMm,
-		}
-	} else {
-		// Camera frame: Z is depth (away from camera), approach is closer to camera
-		approachPoint = r3.Vector{
-			X: obj.Center.X,
-			Y: obj.Center.Y,
-			Z: obj.Center.Z - s.cfg.ApproachOffsetMm,
-		}
-	}
-
-	// Get current gripper orientation in the detection frame for the approach destination.
-	var approachOrientation spatialmath.Orientation
-	if isWorldFrame {
-		gripperPose, err := s.motion.GetPose(ctx, s.cfg.Gripper, "world", nil, nil)
-		if err != nil {
-			s.logger.Warnf("Could not get gripper world pose for orientation, using default: %v", err)
-			approachOrientation = &spatialmath.OrientationVectorDegrees{OX: 0, OY: 1, OZ: 0, Theta: 180}
-		} else {
-			approachOrientation = gripperPose.Pose().Orientation()
-		}
-	} else {
-		approachOrientation = &spatialmath.OrientationVectorDegrees{OZ: 1, Theta: 0}
-	}
-
-	approachPose := spatialmath.NewPose(approachPoint, approachOrientation)
-	approachDest := referenceframe.NewPoseInFrame(detectionFrame, approachPose)
-
-	// Step 3: Move to approach position using motion planning (obstacle-aware)
+	// Step 2: Move to approach position using motion planning (obstacle-aware)
 	s.logger.Infof("Moving to approach position (%.0fmm above object) via motion planning...", s.cfg.ApproachOffsetMm)
 	success, err := s.motion.Move(ctx, motion.MoveReq{
-		ComponentName: s.cfg.Gripper,
-		Destination:   approachDest,
+		ComponentName: s.cfg.toolFrame(),
+		Destination:   pm.Approach,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to move to approach position: %w", err)
@@ -129,69 +275,67 @@ func (s *handEyeTest) executePick(ctx context.Context, obj DetectedObject) (map[
 	if !success {
 		return nil, fmt.Errorf("motion planner could not find path to approach position")
 	}
-	result.StepsCompleted = append(result.StepsCompleted, "approach")
+	s.stepDone(ctx, result, "approach")
 
-	// Step 4: Re-detect from approach position for offset measurement
-	s.logger.Infof("Re-detecting object from approach position...")
-	redetectedObjects, err := detectObjects(ctx, s.camera, s.cfg)
-	if err != nil {
-		s.logger.Warnf("Re-detection failed (non-fatal): %v", err)
-	} else if len(redetectedObjects) > 0 {
-		redetected := redetectedObjects[0]
-		result.ApproachOffsetMm = r3.Vector{
-			X: redetected.Center.X - obj.Center.X,
-			Y: redetected.Center.Y - obj.Center.Y,
-			Z: redetected.Center.Z - obj.Center.Z,
+	// Step 3: Re-detect from approach position for offset measurement. A static camera
+	// has not moved, so it would only see the gripper hovering over the object.
+	if s.cfg.cameraMount() == cameraEyeToHand {
+		s.logger.Infof("Static camera, skipping re-detection from approach position")
+	} else {
+		s.logger.Infof("Re-detecting object from approach position...")
+		redetectedObjects, _, err := s.detect(ctx)
+		if err != nil {
+			s.logger.Warnf("Re-detection failed (non-fatal): %v", err)
+		} else {
+			result.redetected = true
+			match, dist, err := s.matchRedetection(ctx, obj, result.ObjectPositionWorldFrame, redetectedObjects)
+			switch {
+			case err != nil:
+				s.logger.Warnf("Could not match re-detected object (non-fatal): %v", err)
+			case match < 0:
+				s.logger.Warnf("None of the %d re-detected objects is within %.0fmm of the target and a similar size, not measuring the approach offset",
+					len(redetectedObjects), s.cfg.RedetectGateMm)
+			default:
+				redetected := redetectedObjects[match]
+				result.RedetectMatched = true
+				result.RedetectMatchDistanceMm = dist
+				result.approachMeasured = true
+				result.ApproachOffsetMm = r3.Vector{
+					X: redetected.Center.X - obj.Center.X,
+					Y: redetected.Center.Y - obj.Center.Y,
+					Z: redetected.Center.Z - obj.Center.Z,
+				}
+				s.logger.Infof("Approach offset: (%.1f, %.1f, %.1f)mm, total: %.1fmm (matched %.1fmm away in world frame)",
+					result.ApproachOffsetMm.X, result.ApproachOffsetMm.Y, result.ApproachOffsetMm.Z,
+					vecNorm(result.ApproachOffsetMm), dist)
+			}
 		}
-		s.logger.Infof("Approach offset: (%.1f, %.1f, %.1f)mm, total: %.1fmm",
-			result.ApproachOffsetMm.X, result.ApproachOffsetMm.Y, result.ApproachOffsetMm.Z,
-			vecNorm(result.ApproachOffsetMm))
+		s.stepDone(ctx, result, "re_detect")
 	}
-	result.StepsCompleted = append(result.StepsCompleted, "re_detect")
 
-	// Step 5: Move to grasp position using direct Cartesian move via arm driver.
+	// Step 4: Move to grasp position using direct Cartesian move via arm driver.
 	// This is a short straight-line move down from the approach position — no motion planning needed.
 	currentPose, err := s.arm.EndPosition(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get arm position: %w", err)
 	}
-	graspDelta := s.cfg.ApproachOffsetMm - s.cfg.GraspDepthOffsetMm
-	graspPoint := r3.Vector{
-		X: currentPose.Point().X,
-		Y: currentPose.Point().Y,
-		Z: currentPose.Point().Z - graspDelta,
-	}
+	graspPoint := currentPose.Point().Add(pm.Down.Mul(pm.GraspDeltaMm))
 	graspPose := spatialmath.NewPose(graspPoint, currentPose.Orientation())
 
-	s.logger.Infof("Moving to grasp position (%.0fmm below approach, direct Cartesian move)...", graspDelta)
+	s.logger.Infof("Moving to grasp position (%.0fmm past approach, direct Cartesian move)...", pm.GraspDeltaMm)
 	if err := s.arm.MoveToPosition(ctx, graspPose, nil); err != nil {
 		return nil, fmt.Errorf("failed to move to grasp position: %w", err)
 	}
-	result.StepsCompleted = append(result.StepsCompleted, "grasp_position")
+	s.stepDone(ctx, result, "grasp_position")
 
-	// Step 6: World-frame comparison
-	gripperWorldPose, err := s.motion.GetPose(ctx, s.cfg.Gripper, "world", nil, nil)
+	// Step 5: World-frame comparison
+	gripperWorldPose, err := s.motion.GetPose(ctx, s.cfg.toolFrame(), "world", nil, nil)
 	if err != nil {
 		s.logger.Warnf("Could not get gripper world pose (non-fatal): %v", err)
 	} else {
 		gripperPos := gripperWorldPose.Pose().Point()
 		result.GripperPositionWorldFrame = gripperPos
 
-		if isWorldFrame {
-			// Detection was in world frame — object position is already in world frame
-			result.ObjectPositionWorldFrame = obj.Center
-		} else {
-			// Detection was in camera frame — transform to world
-			cameraWorldPose, err := s.motion.GetPose(ctx, s.cfg.Camera, "world", nil, nil)
-			if err != nil {
-				s.logger.Warnf("Could not get camera world pose (non-fatal): %v", err)
-			} else {
-				cameraPose := cameraWorldPose.Pose()
-				objectInWorld := spatialmath.Compose(cameraPose, spatialmath.NewPoseFromPoint(obj.Center))
-				result.ObjectPositionWorldFrame = objectInWorld.Point()
-			}
-		}
-
 		if result.ObjectPositionWorldFrame != (r3.Vector{}) {
 			result.WorldFrameOffsetMm = r3.Vector{
 				X: gripperPos.X - result.ObjectPositionWorldFrame.X,
@@ -201,37 +345,37 @@ func (s *handEyeTest) executePick(ctx context.Context, obj DetectedObject) (map[
 			s.logger.Infof("World-frame offset: (%.1f, %.1f, %.1f)mm, total: %.1fmm",
 				result.WorldFrameOffsetMm.X, result.WorldFrameOffsetMm.Y, result.WorldFrameOffsetMm.Z,
 				vecNorm(result.WorldFrameOffsetMm))
+			if result.DetectionFrames > 1 {
+				s.logger.Infof("Detection noise over %d frames: %.1fmm (std dev)",
+					result.DetectionFrames, vecNorm(result.DetectionNoiseMm))
+			}
 		}
 	}
 
-	// Step 7: Grab
+	// Step 6: Grab
 	s.logger.Infof("Closing gripper...")
 	grabbed, err := s.gripper.Grab(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to grab: %w", err)
 	}
 	s.logger.Infof("Grab reported: %v", grabbed)
-	result.StepsCompleted = append(result.StepsCompleted, "grab")
+	s.stepDone(ctx, result, "grab")
 
-	// Step 8: Lift using direct Cartesian move — short straight-line move up
+	// Step 7: Lift using direct Cartesian move — short straight-line move up
 	s.logger.Infof("Lifting %.0fmm (direct Cartesian move)...", s.cfg.LiftHeightMm)
 	currentPose, err = s.arm.EndPosition(ctx, nil)
 	if err != nil {
 		s.logger.Warnf("Failed to get arm position for lift (non-fatal): %v", err)
 	} else {
-		liftPoint := r3.Vector{
-			X: currentPose.Point().X,
-			Y: currentPose.Point().Y,
-			Z: currentPose.Point().Z + s.cfg.LiftHeightMm,
-		}
+		liftPoint := currentPose.Point().Sub(pm.Down.Mul(s.cfg.LiftHeightMm))
 		liftPose := spatialmath.NewPose(liftPoint, currentPose.Orientation())
 		if err := s.arm.MoveToPosition(ctx, liftPose, nil); err != nil {
 			s.logger.Warnf("Lift move failed (non-fatal): %v", err)
 		}
 	}
-	result.StepsCompleted = append(result.StepsCompleted, "lift")
+	s.stepDone(ctx, result, "lift")
 
-	// Step 9: Verify
+	// Step 8: Verify
 	s.logger.Infof("Verifying hold...")
 	holdingStatus, err := s.gripper.IsHoldingSomething(ctx, nil)
 	if err != nil {
@@ -239,14 +383,26 @@ func (s *handEyeTest) executePick(ctx context.Context, obj DetectedObject) (map[
 	} else {
 		result.IsHolding = holdingStatus.IsHoldingSomething
 	}
-	result.StepsCompleted = append(result.StepsCompleted, "verify")
+	s.stepDone(ctx, result, "verify")
+
+	// Step 9: Optionally put the object back so the next pick starts from a known scene
+	if opts.Place {
+		if !result.IsHolding {
+			s.logger.Infof("Not holding an object, skipping place")
+		} else if err := s.executePlace(ctx, result); err != nil {
+			s.logger.Warnf("Place failed (non-fatal): %v", err)
+			result.Place.Error = err.Error()
+		}
+	}
 
-	result.Success = result.IsHolding
+	// Step 10: Judge the pick against the configured thresholds
+	result.Verdict = s.cfg.Thresholds.judgePick(result, s.cfg.GraspDepthOffsetMm)
+	result.Success = result.Verdict.Pass
 	if result.Success {
 		s.logger.Infof("RESULT: PASS - calibration validated, object picked successfully")
 	} else {
-		s.logger.Infof("RESULT: FAIL - gripper did not hold object")
+		s.logger.Infof("RESULT: FAIL - %s", strings.Join(result.Verdict.Reasons, "; "))
 	}
 
-	return result.toMap(), nil
+	return result, nil
 }
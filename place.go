@@ -0,0 +1,176 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// pickOptions are per-command switches for executePick.
+type pickOptions struct {
+	Place  bool
+	DryRun bool
+}
+
+// placeResult records where a placed object was meant to go and where it was re-detected.
+type placeResult struct {
+	TargetWorldFrame         r3.Vector
+	PlacedPositionWorldFrame r3.Vector
+	PlaceOffsetMm            r3.Vector
+	Redetected               bool
+	Error                    string
+}
+
+func (r *placeResult) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"target_world_frame": map[string]interface{}{
+			"x_mm": r.TargetWorldFrame.X, "y_mm": r.TargetWorldFrame.Y,
+			"z_mm": r.TargetWorldFrame.Z, "frame": "world",
+		},
+		"redetected": r.Redetected,
+	}
+	if r.Redetected {
+		m["placed_position_world_frame"] = map[string]interface{}{
+			"x_mm": r.PlacedPositionWorldFrame.X, "y_mm": r.PlacedPositionWorldFrame.Y,
+			"z_mm": r.PlacedPositionWorldFrame.Z, "frame": "world",
+		}
+		m["place_offset_mm"] = map[string]interface{}{
+			"x": r.PlaceOffsetMm.X, "y": r.PlaceOffsetMm.Y, "z": r.PlaceOffsetMm.Z,
+			"total": vecNorm(r.PlaceOffsetMm),
+		}
+	}
+	if r.Error != "" {
+		m["error"] = r.Error
+	}
+	return m
+}
+
+// executePlace puts a held object back down: at the position it was picked from, or at
+// the configured drop pose. It then opens the gripper, retreats, and re-detects the object
+// to record where it actually ended up, so the next pick can start from a known scene.
+func (s *handEyeTest) executePlace(ctx context.Context, result *pickResult) error {
+	place := &placeResult{}
+	result.Place = place
+
+	// Where the gripper should be when releasing, and where that leaves the object.
+	gripperPose, err := s.motion.GetPose(ctx, s.cfg.toolFrame(), "world", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get gripper world pose: %w", err)
+	}
+	releasePoint := result.GripperPositionWorldFrame
+	releaseOrientation := gripperPose.Pose().Orientation()
+	if dp := s.cfg.Place.DropPose; dp != nil {
+		dropPose := dp.pose()
+		releasePoint = dropPose.Point()
+		releaseOrientation = dropPose.Orientation()
+	}
+	// The object sits where it did relative to the gripper when it was grasped.
+	place.TargetWorldFrame = releasePoint
+	if result.ObjectPositionWorldFrame != (r3.Vector{}) {
+		place.TargetWorldFrame = releasePoint.Add(result.ObjectPositionWorldFrame.Sub(result.GripperPositionWorldFrame))
+	}
+
+	// Lower and retreat along the normal of the table the object was picked from, or the
+	// arm base's Z axis if no table was found.
+	up := r3.Vector{Z: 1}
+	down := r3.Vector{Z: -1}
+	if result.tableUp != (r3.Vector{}) {
+		up = result.tableUp
+		down = s.armBaseDirection(ctx, up.Mul(-1))
+	}
+
+	// Step 1: Move above the release point using motion planning
+	abovePoint := releasePoint.Add(up.Mul(s.cfg.ApproachOffsetMm))
+	s.logger.Infof("Moving above place position (%.1f, %.1f, %.1f)...", abovePoint.X, abovePoint.Y, abovePoint.Z)
+	success, err := s.motion.Move(ctx, motion.MoveReq{
+		ComponentName: s.cfg.toolFrame(),
+		Destination:   referenceframe.NewPoseInFrame("world", spatialmath.NewPose(abovePoint, releaseOrientation)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move above place position: %w", err)
+	}
+	if !success {
+		return fmt.Errorf("motion planner could not find path to place position")
+	}
+	s.stepDone(ctx, result, "place_approach")
+
+	// Step 2: Lower with a direct Cartesian move, mirroring the grasp descent
+	if err := s.moveArmAlong(ctx, down, s.cfg.ApproachOffsetMm); err != nil {
+		return fmt.Errorf("failed to lower to place position: %w", err)
+	}
+	s.stepDone(ctx, result, "place_lower")
+
+	// Step 3: Release
+	s.logger.Infof("Opening gripper to release object...")
+	if err := s.gripper.Open(ctx, nil); err != nil {
+		return fmt.Errorf("failed to open gripper: %w", err)
+	}
+	s.stepDone(ctx, result, "release")
+
+	// Step 4: Retreat back up
+	if err := s.moveArmAlong(ctx, down, -s.cfg.ApproachOffsetMm); err != nil {
+		return fmt.Errorf("failed to retreat after release: %w", err)
+	}
+	s.stepDone(ctx, result, "retreat")
+
+	// Step 5: Re-detect to see where the object really ended up, first parking the arm
+	// out of view if configured (a static camera would otherwise see the gripper).
+	if cp := s.cfg.ClearPose; cp != nil {
+		s.logger.Infof("Moving to clear pose before re-detecting...")
+		success, err := s.motion.Move(ctx, motion.MoveReq{
+			ComponentName: s.cfg.toolFrame(),
+			Destination:   referenceframe.NewPoseInFrame("world", cp.pose()),
+		})
+		if err != nil {
+			s.logger.Warnf("Move to clear pose failed (non-fatal): %v", err)
+		} else if !success {
+			s.logger.Warnf("Motion planner could not find path to clear pose (non-fatal)")
+		}
+	}
+	s.logger.Infof("Re-detecting placed object...")
+	objects, _, err := s.detect(ctx)
+	if err != nil {
+		s.logger.Warnf("Re-detection after place failed (non-fatal): %v", err)
+	} else {
+		var placed DetectedObject
+		bestDist := math.Inf(1)
+		for _, obj := range objects {
+			worldPos, err := s.toWorldFrame(ctx, obj.Center, s.cfg.detectionFrame())
+			if err != nil {
+				s.logger.Warnf("Could not transform placed object to world frame (non-fatal): %v", err)
+				break
+			}
+			if d := vecNorm(worldPos.Sub(place.TargetWorldFrame)); d < bestDist {
+				bestDist = d
+				placed = obj
+				place.PlacedPositionWorldFrame = worldPos
+				place.Redetected = true
+			}
+		}
+		if place.Redetected {
+			place.PlaceOffsetMm = place.PlacedPositionWorldFrame.Sub(place.TargetWorldFrame)
+			s.logger.Infof("Place offset: (%.1f, %.1f, %.1f)mm, total: %.1fmm",
+				place.PlaceOffsetMm.X, place.PlaceOffsetMm.Y, place.PlaceOffsetMm.Z, vecNorm(place.PlaceOffsetMm))
+			s.recordPlaceSample(ctx, placed, place.TargetWorldFrame)
+		}
+	}
+	s.stepDone(ctx, result, "place_verify")
+	return nil
+}
+
+// moveArmAlong moves the arm end effector in a straight line along a direction in the
+// arm base frame, with a direct Cartesian move through the arm driver.
+func (s *handEyeTest) moveArmAlong(ctx context.Context, dir r3.Vector, distanceMm float64) error {
+	currentPose, err := s.arm.EndPosition(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get arm position: %w", err)
+	}
+	target := currentPose.Point().Add(dir.Mul(distanceMm))
+	return s.arm.MoveToPosition(ctx, spatialmath.NewPose(target, currentPose.Orientation()), nil)
+}
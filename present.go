@@ -0,0 +1,196 @@
+package handeyetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// presentMatchMm is how far from where the target should be a detection can be and still
+// count as the target. Anything further is more likely something else on the table.
+const presentMatchMm = 50
+
+// presentationPoses returns the world-frame gripper poses to show the target at.
+func (pc *PresentConfig) presentationPoses() ([]spatialmath.Pose, error) {
+	if len(pc.Poses) > 0 {
+		poses := make([]spatialmath.Pose, len(pc.Poses))
+		for i, p := range pc.Poses {
+			poses[i] = p.pose()
+		}
+		return poses, nil
+	}
+	if len(pc.Center) != 3 || pc.Count <= 0 {
+		return nil, fmt.Errorf("present needs either 'poses' or 'center' with 'count' > 0")
+	}
+
+	// The center, then a ring around it at the same height, gripper pointing down.
+	center := r3.Vector{X: pc.Center[0], Y: pc.Center[1], Z: pc.Center[2]}
+	down := &spatialmath.OrientationVectorDegrees{OZ: -1}
+	poses := []spatialmath.Pose{spatialmath.NewPose(center, down)}
+	for i := 0; i < pc.Count; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(pc.Count)
+		pos := r3.Vector{
+			X: center.X + pc.RadiusMm*math.Cos(angle),
+			Y: center.Y + pc.RadiusMm*math.Sin(angle),
+			Z: center.Z,
+		}
+		poses = append(poses, spatialmath.NewPose(pos, down))
+	}
+	return poses, nil
+}
+
+// targetOffset returns the target's detected point in the gripper frame.
+func (pc *PresentConfig) targetOffset() r3.Vector {
+	if len(pc.TargetOffsetMm) == 3 {
+		return r3.Vector{X: pc.TargetOffsetMm[0], Y: pc.TargetOffsetMm[1], Z: pc.TargetOffsetMm[2]}
+	}
+	return r3.Vector{}
+}
+
+// parsePresentConfig overrides fields of the configured presentation with any matching
+// keys in the DoCommand (e.g. "poses", "center", "target_offset_mm").
+func parsePresentConfig(cmd map[string]interface{}, defaults PresentConfig) (PresentConfig, error) {
+	// Unmarshal reuses a slice's backing array, so copy them to keep the defaults intact.
+	presentCfg := defaults
+	presentCfg.Poses = append([]PoseConfig(nil), defaults.Poses...)
+	presentCfg.Center = append([]float64(nil), defaults.Center...)
+	presentCfg.TargetOffsetMm = append([]float64(nil), defaults.TargetOffsetMm...)
+	raw, err := json.Marshal(cmd)
+	if err != nil {
+		return presentCfg, err
+	}
+	if err := json.Unmarshal(raw, &presentCfg); err != nil {
+		return presentCfg, fmt.Errorf("invalid present parameters: %w", err)
+	}
+	return presentCfg, nil
+}
+
+// handlePresent is the validation sweep for a static camera. The arm shows a held target
+// to the camera at each pose, and the target's detected world position is compared with
+// where the arm's kinematics put it. The mean of those offsets is the calibration error;
+// their spread is what varies with where the target is in view.
+func (s *handEyeTest) handlePresent(ctx context.Context, presentCfg PresentConfig) (map[string]interface{}, error) {
+	if s.cfg.cameraMount() != cameraEyeToHand {
+		return nil, fmt.Errorf("present needs a static camera (camera_mount %q); use sweep for a camera on the arm", cameraEyeToHand)
+	}
+	poses, err := presentCfg.presentationPoses()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.currentStatus = "presenting"
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.currentStatus = "idle"
+		s.mu.Unlock()
+	}()
+
+	if status, err := s.gripper.IsHoldingSomething(ctx, nil); err == nil && !status.IsHoldingSomething {
+		s.logger.Warnf("Gripper reports it is not holding anything; the target must be fixed to it")
+	}
+
+	detectionFrame := s.cfg.detectionFrame()
+	targetOffset := presentCfg.targetOffset()
+	var offsets []r3.Vector
+	var totals scalarSeries
+	rows := make([]interface{}, 0, len(poses))
+
+	// observe moves to one pose and records what the camera sees in the pose's row.
+	observe := func(i int, pose spatialmath.Pose, row map[string]interface{}) {
+		s.logger.Infof("Present pose %d/%d: moving to (%.1f, %.1f, %.1f)...",
+			i+1, len(poses), pose.Point().X, pose.Point().Y, pose.Point().Z)
+		success, err := s.motion.Move(ctx, motion.MoveReq{
+			ComponentName: s.cfg.toolFrame(),
+			Destination:   referenceframe.NewPoseInFrame("world", pose),
+		})
+		if err != nil {
+			row["error"] = fmt.Sprintf("move failed: %v", err)
+			return
+		}
+		if !success {
+			row["error"] = "motion planner could not find path"
+			return
+		}
+
+		// Where the target is according to the arm, which does not depend on the camera.
+		gripperPose, err := s.motion.GetPose(ctx, s.cfg.toolFrame(), "world", nil, nil)
+		if err != nil {
+			row["error"] = fmt.Sprintf("could not get gripper world pose: %v", err)
+			return
+		}
+		expected := spatialmath.Compose(gripperPose.Pose(), spatialmath.NewPoseFromPoint(targetOffset)).Point()
+		row["expected_position_world_frame"] = map[string]interface{}{
+			"x_mm": expected.X, "y_mm": expected.Y, "z_mm": expected.Z, "frame": "world",
+		}
+
+		objects, _, err := s.detect(ctx)
+		if err != nil {
+			row["error"] = fmt.Sprintf("detection failed: %v", err)
+			return
+		}
+		var best r3.Vector
+		bestDist := math.Inf(1)
+		for _, obj := range objects {
+			worldPos, err := s.toWorldFrame(ctx, obj.Center, detectionFrame)
+			if err != nil {
+				row["error"] = err.Error()
+				break
+			}
+			if d := vecNorm(worldPos.Sub(expected)); d < bestDist {
+				best, bestDist = worldPos, d
+			}
+		}
+		if bestDist > presentMatchMm {
+			if _, failed := row["error"]; !failed {
+				row["error"] = fmt.Sprintf("target not detected within %dmm of where the arm holds it (%d objects)",
+					presentMatchMm, len(objects))
+			}
+			return
+		}
+
+		offset := best.Sub(expected)
+		offsets = append(offsets, offset)
+		totals = append(totals, vecNorm(offset))
+		row["object_position_world_frame"] = map[string]interface{}{
+			"x_mm": best.X, "y_mm": best.Y, "z_mm": best.Z, "frame": "world",
+		}
+		row["world_frame_offset_mm"] = map[string]interface{}{
+			"x": offset.X, "y": offset.Y, "z": offset.Z, "total": vecNorm(offset),
+		}
+		s.logger.Infof("Present pose %d/%d: target seen %.1fmm from where the arm holds it",
+			i+1, len(poses), vecNorm(offset))
+	}
+	for i, pose := range poses {
+		row := map[string]interface{}{"index": i, "pose": poseToMap(pose)}
+		rows = append(rows, row)
+		observe(i, pose, row)
+		s.reportProgress(ctx, fmt.Sprintf("pose_%d", i+1))
+	}
+
+	stats := computeVectorStats(offsets)
+	s.logger.Infof("Present complete: %d/%d poses observed the target, mean offset %.2fmm, std-dev %.2fmm",
+		stats.Count, len(poses), vecNorm(stats.Mean), vecNorm(stats.StdDev))
+
+	result := map[string]interface{}{
+		"success":                     stats.Count >= 2,
+		"poses":                       len(poses),
+		"observed":                    stats.Count,
+		"world_frame_offset_mm":       stats.toMap(),
+		"world_frame_offset_total_mm": totals.toMap(),
+		"observations":                rows,
+	}
+	s.mu.Lock()
+	s.lastResult = result
+	s.mu.Unlock()
+	s.recordHistory("present", result)
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package handeyetest
+
+import (
+	"fmt"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	pc "go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// Frames a region of interest can be given in.
+const (
+	roiWorld  = "world"
+	roiCamera = "camera"
+)
+
+// frame returns the ROI's frame, defaulting to world.
+func (roi *ROIConfig) frame() string {
+	if roi.Frame != "" {
+		return roi.Frame
+	}
+	return roiWorld
+}
+
+// roiRegion is a region of interest prepared for testing many points: its polygon is built
+// once rather than for every point.
+type roiRegion struct {
+	roi  *ROIConfig
+	poly []r2.Point
+}
+
+func newROIRegion(roi *ROIConfig) roiRegion {
+	region := roiRegion{roi: roi}
+	for _, v := range roi.Polygon {
+		region.poly = append(region.poly, r2.Point{X: v[0], Y: v[1]})
+	}
+	return region
+}
+
+// contains reports whether a point, in the ROI's frame, is inside its box and polygon.
+func (region roiRegion) contains(p r3.Vector) bool {
+	if roi := region.roi; roi.MinMm != nil {
+		if p.X < roi.MinMm[0] || p.Y < roi.MinMm[1] || p.Z < roi.MinMm[2] ||
+			p.X > roi.MaxMm[0] || p.Y > roi.MaxMm[1] || p.Z > roi.MaxMm[2] {
+			return false
+		}
+	}
+	if region.poly != nil {
+		return insidePolygon(region.poly, r2.Point{X: p.X, Y: p.Y})
+	}
+	return true
+}
+
+// insidePolygon reports whether a point lies inside a simple polygon, by counting how many
+// of its edges a ray from the point crosses.
+func insidePolygon(poly []r2.Point, p r2.Point) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// cropStats counts what a region of interest removed before segmentation.
+type cropStats struct {
+	PointsRemoved   int
+	ClustersRemoved int
+}
+
+func (cs cropStats) toMap() map[string]interface{} {
+	return map[string]interface{}{"points_removed": cs.PointsRemoved, "clusters_removed": cs.ClustersRemoved}
+}
+
+// cropCloud splits a cloud into the points inside the ROI and those outside. cloudPose is
+// the cloud frame's world pose, which a world-frame ROI needs (nil if unknown).
+func cropCloud(cloud pc.PointCloud, roi *ROIConfig, cloudPose spatialmath.Pose) (pc.PointCloud, pc.PointCloud, error) {
+	toROI := func(p r3.Vector) r3.Vector { return p }
+	if roi.frame() == roiWorld {
+		if cloudPose == nil {
+			return nil, nil, fmt.Errorf("a world-frame region of interest needs the cloud's world pose")
+		}
+		toROI = func(p r3.Vector) r3.Vector {
+			return spatialmath.Compose(cloudPose, spatialmath.NewPoseFromPoint(p)).Point()
+		}
+	}
+	region := newROIRegion(roi)
+	inside, outside := pc.NewBasicEmpty(), pc.NewBasicEmpty()
+	var err error
+	cloud.Iterate(0, 0, func(p r3.Vector, d pc.Data) bool {
+		if region.contains(toROI(p)) {
+			err = inside.Set(p, d)
+		} else {
+			err = outside.Set(p, d)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return inside, outside, nil
+}
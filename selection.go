@@ -0,0 +1,175 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// Selection strategies order detected objects, so that object_index 0 is the one the
+// strategy prefers and the same index means the same object between detect and
+// pick_detected.
+const (
+	selectNearestCamera = "nearest_camera"
+	selectLargest       = "largest"
+	selectNearestPoint  = "nearest_point"
+	selectHighest       = "highest"
+	selectLeftmost      = "leftmost"
+)
+
+// selection is a strategy with its parameters. Point is the world XY for nearest_point.
+type selection struct {
+	Strategy string
+	Point    []float64
+}
+
+// strategy returns the selection strategy, defaulting to nearest_camera.
+func (sel selection) strategy() string {
+	if sel.Strategy != "" {
+		return sel.Strategy
+	}
+	return selectNearestCamera
+}
+
+func validateSelection(strategy string, point []float64) error {
+	switch strategy {
+	case "", selectNearestCamera, selectLargest, selectHighest, selectLeftmost:
+		return nil
+	case selectNearestPoint:
+		if len(point) != 2 {
+			return fmt.Errorf("selection %q needs selection_point as [x, y] in world frame, got %v", selectNearestPoint, point)
+		}
+		return nil
+	default:
+		return fmt.Errorf("selection must be %q, %q, %q, %q or %q, got %q",
+			selectNearestCamera, selectLargest, selectNearestPoint, selectHighest, selectLeftmost, strategy)
+	}
+}
+
+// selectionFromCommand reads "selection" and "selection_point" from a DoCommand, falling
+// back to the service config.
+func (s *handEyeTest) selectionFromCommand(cmd map[string]interface{}) (selection, error) {
+	sel := selection{Strategy: s.cfg.Selection, Point: s.cfg.SelectionPoint}
+	if strategy, ok := cmd["selection"].(string); ok {
+		sel.Strategy = strategy
+	}
+	if raw, ok := cmd["selection_point"].([]interface{}); ok {
+		sel.Point = nil
+		for _, v := range raw {
+			f, ok := v.(float64)
+			if !ok {
+				return selection{}, fmt.Errorf("selection_point must be numbers, got %v", raw)
+			}
+			sel.Point = append(sel.Point, f)
+		}
+	}
+	if err := validateSelection(sel.Strategy, sel.Point); err != nil {
+		return selection{}, err
+	}
+	return sel, nil
+}
+
+// detectSelected detects objects and orders them by the selection strategy. It also
+// returns what the region of interest cropped.
+func (s *handEyeTest) detectSelected(ctx context.Context, sel selection) ([]DetectedObject, cropStats, error) {
+	objects, crop, err := s.detect(ctx)
+	if err != nil {
+		return nil, crop, err
+	}
+	detectionFrame := s.cfg.detectionFrame()
+	toWorld := func(p r3.Vector) (r3.Vector, error) { return s.toWorldFrame(ctx, p, detectionFrame) }
+	// The camera-based strategies rank in the camera frame, whatever frame detections are in.
+	var detectionInCamera spatialmath.Pose
+	if detectionFrame != s.cfg.Camera {
+		cameraInDetection, err := s.motion.GetPose(ctx, s.cfg.Camera, detectionFrame, nil, nil)
+		if err != nil {
+			return nil, crop, fmt.Errorf("failed to get camera pose in %s frame: %w", detectionFrame, err)
+		}
+		detectionInCamera = spatialmath.PoseInverse(cameraInDetection.Pose())
+	}
+	if err := sortDetections(objects, sel, detectionInCamera, toWorld); err != nil {
+		return nil, crop, err
+	}
+	return objects, crop, nil
+}
+
+// sortDetections orders objects by the selection strategy, defaulting to nearest_camera.
+// detectionInCamera is the detection frame's pose in the camera frame, nil if centers are
+// already in the camera frame. toWorld transforms centers to world for the strategies that
+// need it, and may be nil if there is no world frame (e.g. a PCD file). Ties go to the
+// object nearer the camera.
+func sortDetections(
+	objects []DetectedObject, sel selection, detectionInCamera spatialmath.Pose, toWorld func(r3.Vector) (r3.Vector, error),
+) error {
+	inCamera := make([]r3.Vector, len(objects))
+	for i, obj := range objects {
+		inCamera[i] = obj.Center
+		if detectionInCamera != nil {
+			inCamera[i] = spatialmath.Compose(detectionInCamera, spatialmath.NewPoseFromPoint(obj.Center)).Point()
+		}
+	}
+	keys := make([]float64, len(objects))
+	for i, obj := range objects {
+		switch sel.strategy() {
+		case selectNearestCamera:
+			keys[i] = vecNorm(inCamera[i])
+		case selectLargest:
+			keys[i] = -float64(obj.PointCount)
+		case selectLeftmost:
+			// Leftmost in the image: the smallest horizontal angle off the optical axis.
+			keys[i] = math.Atan2(inCamera[i].X, inCamera[i].Z)
+		case selectHighest:
+			if obj.Plane != nil {
+				keys[i] = -obj.HeightMm
+				continue
+			}
+			world, err := worldPosition(obj, sel.Strategy, toWorld)
+			if err != nil {
+				return err
+			}
+			keys[i] = -world.Z
+		case selectNearestPoint:
+			world, err := worldPosition(obj, sel.Strategy, toWorld)
+			if err != nil {
+				return err
+			}
+			keys[i] = math.Hypot(world.X-sel.Point[0], world.Y-sel.Point[1])
+		default:
+			return validateSelection(sel.Strategy, sel.Point)
+		}
+	}
+
+	order := make([]int, len(objects))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ka, kb := keys[order[a]], keys[order[b]]
+		if ka != kb {
+			return ka < kb
+		}
+		return vecNorm(inCamera[order[a]]) < vecNorm(inCamera[order[b]])
+	})
+	sorted := make([]DetectedObject, len(objects))
+	for i, j := range order {
+		sorted[i] = objects[j]
+	}
+	copy(objects, sorted)
+	return nil
+}
+
+func worldPosition(obj DetectedObject, strategy string, toWorld func(r3.Vector) (r3.Vector, error)) (r3.Vector, error) {
+	if toWorld == nil {
+		return r3.Vector{}, fmt.Errorf("selection %q needs the world frame, which is not available here", strategy)
+	}
+	world, err := toWorld(obj.Center)
+	if err != nil {
+		return r3.Vector{}, fmt.Errorf("selection %q: %w", strategy, err)
+	}
+	return world, nil
+}
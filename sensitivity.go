@@ -0,0 +1,461 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/logging"
+	generic "go.viam.com/rdk/services/generic"
+	"go.viam.com/rdk/spatialmath"
+
+	"handeyetest/sim"
+)
+
+const (
+	sensitivityPick  = "pick"
+	sensitivitySweep = "sweep"
+
+	// sensitivityRingMm is how far from its starting position the object is moved
+	// between picks, so the calibration samples are spread over the table.
+	sensitivityRingMm = 60
+)
+
+// sensitivityOptions configures a sensitivity run: which calibration errors to inject
+// into the simulated cell, and how to look for them.
+type sensitivityOptions struct {
+	Mode           string
+	TranslationsMm []float64
+	RotationsDeg   []float64
+	Axes           []string
+	Picks          int
+	SweepPoses     int
+	ToleranceMm    float64
+	NoiseMm        float64
+}
+
+func validateSensitivityMode(mode string) error {
+	switch mode {
+	case sensitivityPick, sensitivitySweep:
+		return nil
+	default:
+		return fmt.Errorf("invalid mode %q: must be %s or %s", mode, sensitivityPick, sensitivitySweep)
+	}
+}
+
+// injectedError is a known error on the camera mount, applied in the camera frame.
+type injectedError struct {
+	Kind      string
+	Axis      string
+	Magnitude float64
+}
+
+func (e injectedError) pose() spatialmath.Pose {
+	axis := map[string]r3.Vector{"x": {X: 1}, "y": {Y: 1}, "z": {Z: 1}}[e.Axis]
+	switch e.Kind {
+	case "translation":
+		return spatialmath.NewPoseFromPoint(axis.Mul(e.Magnitude))
+	case "rotation":
+		return spatialmath.NewPoseFromOrientation(&spatialmath.R4AA{
+			Theta: e.Magnitude * math.Pi / 180, RX: axis.X, RY: axis.Y, RZ: axis.Z,
+		})
+	default:
+		return spatialmath.NewZeroPose()
+	}
+}
+
+func (e injectedError) unit() string {
+	if e.Kind == "rotation" {
+		return "deg"
+	}
+	return "mm"
+}
+
+func (e injectedError) label() string {
+	if e.Kind == "none" {
+		return "baseline"
+	}
+	return fmt.Sprintf("%s %s %g%s", e.Kind, e.Axis, e.Magnitude, e.unit())
+}
+
+// correctionCheck compares a solved camera mount with the true one.
+type correctionCheck struct {
+	TranslationOnly    bool
+	TranslationErrorMm float64
+	RotationErrorDeg   float64
+	ErrorAtObjectMm    float64
+}
+
+// sensitivityRow is the outcome for one injected error.
+type sensitivityRow struct {
+	Injected injectedError
+	// ExpectedOffsetMm is how far the injected error misplaces the object as seen from
+	// the arm's home pose: what a perfect validator would report.
+	ExpectedOffsetMm r3.Vector
+
+	Picks              int
+	Succeeded          int
+	WorldFrameOffsetMm r3.Vector
+	PlaceOffsetMm      r3.Vector
+	PlaceOffsetErrorMm float64
+	Correction         *correctionCheck
+	CorrectionError    string
+
+	Sweep       vectorStats
+	SweepBiasMm float64
+
+	Detected  bool
+	Recovered bool
+	Error     string
+}
+
+func (r *sensitivityRow) toMap(mode string) map[string]interface{} {
+	m := map[string]interface{}{
+		"kind":      r.Injected.Kind,
+		"magnitude": r.Injected.Magnitude,
+		"unit":      r.Injected.unit(),
+		"expected_offset_mm": map[string]interface{}{
+			"x": r.ExpectedOffsetMm.X, "y": r.ExpectedOffsetMm.Y, "z": r.ExpectedOffsetMm.Z,
+			"total": vecNorm(r.ExpectedOffsetMm),
+		},
+		"detected": r.Detected,
+	}
+	if r.Injected.Axis != "" {
+		m["axis"] = r.Injected.Axis
+	}
+	if r.Error != "" {
+		m["error"] = r.Error
+	}
+	if mode == sensitivitySweep {
+		m["world_position"] = r.Sweep.toMap()
+		m["bias_mm"] = r.SweepBiasMm
+		return m
+	}
+	m["picks"] = r.Picks
+	m["succeeded"] = r.Succeeded
+	m["world_frame_offset_mm"] = map[string]interface{}{
+		"x": r.WorldFrameOffsetMm.X, "y": r.WorldFrameOffsetMm.Y, "z": r.WorldFrameOffsetMm.Z,
+		"total": vecNorm(r.WorldFrameOffsetMm),
+	}
+	m["place_offset_mm"] = map[string]interface{}{
+		"x": r.PlaceOffsetMm.X, "y": r.PlaceOffsetMm.Y, "z": r.PlaceOffsetMm.Z,
+		"total": vecNorm(r.PlaceOffsetMm),
+	}
+	m["place_offset_error_mm"] = r.PlaceOffsetErrorMm
+	if r.Correction != nil {
+		m["correction"] = map[string]interface{}{
+			"translation_only":     r.Correction.TranslationOnly,
+			"translation_error_mm": r.Correction.TranslationErrorMm,
+			"rotation_error_deg":   r.Correction.RotationErrorDeg,
+			"error_at_object_mm":   r.Correction.ErrorAtObjectMm,
+		}
+	}
+	if r.CorrectionError != "" {
+		m["correction_error"] = r.CorrectionError
+	}
+	m["recovered"] = r.Recovered
+	return m
+}
+
+// sensitivityReport is the full table, with the baseline run every row is judged against.
+type sensitivityReport struct {
+	Options  sensitivityOptions
+	Baseline *sensitivityRow
+	Rows     []*sensitivityRow
+}
+
+// smallestDetectable returns, per error kind and axis, the smallest injected magnitude
+// from which every larger one tested was also detected.
+func (r *sensitivityReport) smallestDetectable() map[string]interface{} {
+	byKey := map[string][]*sensitivityRow{}
+	var keys []string
+	for _, row := range r.Rows {
+		key := row.Injected.Kind + "_" + row.Injected.Axis
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], row)
+	}
+	out := map[string]interface{}{}
+	for _, key := range keys {
+		rows := byKey[key]
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Injected.Magnitude < rows[j].Injected.Magnitude })
+		var smallest interface{}
+		for i := len(rows) - 1; i >= 0 && rows[i].Detected; i-- {
+			smallest = rows[i].Injected.Magnitude
+		}
+		out[key] = smallest
+	}
+	return out
+}
+
+func (r *sensitivityReport) toMap() map[string]interface{} {
+	rows := make([]interface{}, len(r.Rows))
+	for i, row := range r.Rows {
+		rows[i] = row.toMap(r.Options.Mode)
+	}
+	return map[string]interface{}{
+		"mode":                r.Options.Mode,
+		"tolerance_mm":        r.Options.ToleranceMm,
+		"depth_noise_mm":      r.Options.NoiseMm,
+		"baseline":            r.Baseline.toMap(r.Options.Mode),
+		"rows":                rows,
+		"smallest_detectable": r.smallestDetectable(),
+	}
+}
+
+// writeTable prints the injected errors against the measured offsets, one line each.
+func (r *sensitivityReport) writeTable(w io.Writer) {
+	rows := append([]*sensitivityRow{r.Baseline}, r.Rows...)
+	if r.Options.Mode == sensitivitySweep {
+		fmt.Fprintf(w, "%-22s %10s %12s %12s %9s %9s\n",
+			"injected", "expected", "std-dev", "max dev", "bias", "detected")
+		for _, row := range rows {
+			fmt.Fprintf(w, "%-22s %8.2fmm %10.2fmm %10.2fmm %7.2fmm %9v\n",
+				row.Injected.label(), vecNorm(row.ExpectedOffsetMm), vecNorm(row.Sweep.StdDev),
+				row.Sweep.MaxDeviationMm, row.SweepBiasMm, row.Detected)
+		}
+		return
+	}
+	fmt.Fprintf(w, "%-22s %10s %7s %12s %12s %12s %9s %9s\n",
+		"injected", "expected", "picks", "world off", "place off", "corrected", "detected", "recovered")
+	for _, row := range rows {
+		corrected := "-"
+		if row.Correction != nil {
+			corrected = fmt.Sprintf("%.2fmm", row.Correction.ErrorAtObjectMm)
+			if row.Correction.TranslationOnly {
+				corrected += "*"
+			}
+		}
+		fmt.Fprintf(w, "%-22s %8.2fmm %3d/%-3d %10.2fmm %10.2fmm %12s %9v %9v\n",
+			row.Injected.label(), vecNorm(row.ExpectedOffsetMm), row.Succeeded, row.Picks,
+			vecNorm(row.WorldFrameOffsetMm), vecNorm(row.PlaceOffsetMm), corrected, row.Detected, row.Recovered)
+	}
+	fmt.Fprintln(w, "(* translation-only correction: the samples could not constrain rotation)")
+}
+
+// runSensitivity injects each requested calibration error into a fresh simulated cell,
+// runs picks (or a sweep) against it exactly as on a real machine, and compares what the
+// tester measured with the error that was injected. A zero-error baseline is run first;
+// an error counts as detected once the measurement departs from the baseline by more
+// than the tolerance.
+func runSensitivity(ctx context.Context, opts sensitivityOptions, logger logging.Logger) (*sensitivityReport, error) {
+	if err := validateSensitivityMode(opts.Mode); err != nil {
+		return nil, err
+	}
+	if opts.Mode == sensitivityPick && opts.Picks < 3 {
+		return nil, fmt.Errorf("picks must be at least 3 to solve a correction")
+	}
+	for _, axis := range opts.Axes {
+		if axis != "x" && axis != "y" && axis != "z" {
+			return nil, fmt.Errorf("invalid axis %q: must be x, y or z", axis)
+		}
+	}
+
+	var injected []injectedError
+	for _, axis := range opts.Axes {
+		for _, mag := range opts.TranslationsMm {
+			injected = append(injected, injectedError{Kind: "translation", Axis: axis, Magnitude: mag})
+		}
+		for _, mag := range opts.RotationsDeg {
+			injected = append(injected, injectedError{Kind: "rotation", Axis: axis, Magnitude: mag})
+		}
+	}
+
+	report := &sensitivityReport{Options: opts}
+	baseline, err := runInjectedError(ctx, opts, injectedError{Kind: "none"}, logger)
+	if err != nil {
+		return nil, fmt.Errorf("baseline run failed: %w", err)
+	}
+	report.Baseline = baseline
+
+	for i, inj := range injected {
+		logger.Infof("Sensitivity %d/%d: %s", i+1, len(injected), inj.label())
+		row, err := runInjectedError(ctx, opts, inj, logger)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", inj.label(), err)
+		}
+		row.Detected = row.departsFrom(baseline, opts)
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+// departsFrom reports whether a row's measurement differs from the baseline's by more
+// than the tolerance. In pick mode a pick that fails where the baseline succeeded also
+// counts: the error was large enough to miss the object.
+func (r *sensitivityRow) departsFrom(baseline *sensitivityRow, opts sensitivityOptions) bool {
+	if r.Error != "" {
+		return true
+	}
+	if opts.Mode == sensitivitySweep {
+		return r.Sweep.MaxDeviationMm > baseline.Sweep.MaxDeviationMm+opts.ToleranceMm
+	}
+	if r.Succeeded < baseline.Succeeded {
+		return true
+	}
+	return vecNorm(r.PlaceOffsetMm.Sub(baseline.PlaceOffsetMm)) > opts.ToleranceMm
+}
+
+// runInjectedError builds a simulated cell carrying one calibration error and measures it.
+func runInjectedError(ctx context.Context, opts sensitivityOptions, inj injectedError, logger logging.Logger) (*sensitivityRow, error) {
+	cellCfg := sim.DefaultConfig()
+	cellCfg.CalibrationError = inj.pose()
+	cellCfg.DepthNoiseMm = opts.NoiseMm
+	cell := sim.NewCell(cellCfg)
+
+	cfg := Config{
+		Arm: cellCfg.Arm, Camera: cellCfg.Camera, Gripper: cellCfg.Gripper,
+		GraspDepthOffsetMm: -15,
+		Place:              PlaceConfig{Enabled: true},
+	}
+	if _, _, err := cfg.Validate("sensitivity"); err != nil {
+		return nil, err
+	}
+	// The service logs every pick step; only pass its warnings through unless debugging.
+	svcLogger := logger.Sublogger("sim")
+	if logger.GetLevel() != logging.DEBUG {
+		svcLogger.SetLevel(logging.WARN)
+	}
+	res, err := NewHandEyeTest(ctx, cell.Dependencies(), generic.Named("sensitivity"), &cfg, svcLogger)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close(ctx)
+	svc := res.(*handEyeTest)
+
+	start := cell.Objects()[0]
+	row := &sensitivityRow{Injected: inj, ExpectedOffsetMm: cell.ApparentError(start.Top())}
+	if opts.Mode == sensitivitySweep {
+		svc.sensitivitySweep(ctx, cell, opts, row)
+		return row, nil
+	}
+	svc.sensitivityPicks(ctx, cell, opts, row)
+	svc.sensitivityCorrection(ctx, cell, cellCfg.CameraMount, start, row)
+	row.Recovered = row.Error == "" && row.Succeeded == row.Picks &&
+		row.PlaceOffsetErrorMm <= opts.ToleranceMm &&
+		row.Correction != nil && row.Correction.ErrorAtObjectMm <= opts.ToleranceMm
+	return row, nil
+}
+
+// sensitivityPicks runs pick-and-place cycles with the object moved around a ring between
+// them, averaging the offsets the tester reports.
+func (s *handEyeTest) sensitivityPicks(ctx context.Context, cell *sim.Cell, opts sensitivityOptions, row *sensitivityRow) {
+	start := cell.Objects()[0].Position
+	var worldOffsets, placeOffsets, expected []r3.Vector
+	for i := 0; i < opts.Picks; i++ {
+		row.Picks++
+		angle := 2 * math.Pi * float64(i) / float64(opts.Picks)
+		cell.ResetArm()
+		if err := cell.MoveObject(0, start.X+sensitivityRingMm*math.Cos(angle), start.Y+sensitivityRingMm*math.Sin(angle)); err != nil {
+			row.Error = err.Error()
+			return
+		}
+
+		objects, _, err := s.detect(ctx)
+		if err != nil || len(objects) == 0 {
+			s.logger.Warnf("Pick %d: object not detected (%v)", i+1, err)
+			continue
+		}
+		result, err := s.executePick(ctx, objects[0], pickOptions{Place: true})
+		if err != nil {
+			s.logger.Warnf("Pick %d failed: %v", i+1, err)
+			continue
+		}
+		if !result.Success {
+			continue
+		}
+		row.Succeeded++
+		worldOffsets = append(worldOffsets, result.WorldFrameOffsetMm)
+		if result.Place != nil && result.Place.Redetected {
+			placeOffsets = append(placeOffsets, result.Place.PlaceOffsetMm)
+			// The arm is still where it re-detected the placed object from.
+			expected = append(expected, cell.ApparentError(cell.Objects()[0].Top()))
+		}
+	}
+	row.WorldFrameOffsetMm = computeVectorStats(worldOffsets).Mean
+	row.PlaceOffsetMm = computeVectorStats(placeOffsets).Mean
+	row.PlaceOffsetErrorMm = vecNorm(row.PlaceOffsetMm.Sub(computeVectorStats(expected).Mean))
+}
+
+// sensitivityCorrection solves a camera correction from the place samples the picks
+// collected and checks it against the true camera mount. Where the samples cannot
+// constrain rotation it falls back to a translation-only fit.
+func (s *handEyeTest) sensitivityCorrection(
+	ctx context.Context, cell *sim.Cell, trueMount spatialmath.Pose, start sim.Object, row *sensitivityRow,
+) {
+	check := &correctionCheck{}
+	result, err := s.solveCorrection(ctx, false)
+	if err != nil {
+		check.TranslationOnly = true
+		result, err = s.solveCorrection(ctx, true)
+	}
+	if err != nil {
+		row.CorrectionError = err.Error()
+		return
+	}
+	check.TranslationErrorMm = vecNorm(result.Corrected.Point().Sub(trueMount.Point()))
+	delta := spatialmath.PoseDelta(trueMount, result.Corrected)
+	check.RotationErrorDeg = delta.Orientation().AxisAngles().Theta * 180 / math.Pi
+
+	// What matters is where the corrected calibration puts the object: apply it to the
+	// cell and look from home again.
+	cell.SetCalibrationError(spatialmath.Compose(spatialmath.PoseInverse(trueMount), result.Corrected))
+	cell.ResetArm()
+	if err := cell.MoveObject(0, start.Position.X, start.Position.Y); err != nil {
+		row.CorrectionError = err.Error()
+		return
+	}
+	check.ErrorAtObjectMm = vecNorm(cell.ApparentError(start.Top()))
+	row.Correction = check
+}
+
+// sensitivitySweep runs a ring sweep around the object and records the spread of its
+// apparent world position, plus the bias of the mean from the object's true position
+// (which only the simulation knows).
+func (s *handEyeTest) sensitivitySweep(ctx context.Context, cell *sim.Cell, opts sensitivityOptions, row *sensitivityRow) {
+	top := cell.Objects()[0].Top()
+	sweepCfg := SweepConfig{
+		Target:   []float64{top.X, top.Y, top.Z},
+		RadiusMm: 100,
+		HeightMm: 300,
+		Count:    opts.SweepPoses,
+	}
+	poses, err := sweepCfg.observationPoses()
+	if err != nil {
+		row.Error = err.Error()
+		return
+	}
+	row.Sweep, _, _, err = s.sweep(ctx, poses, 0, selection{})
+	if err != nil {
+		row.Error = err.Error()
+		return
+	}
+	if row.Sweep.Count < 2 {
+		row.Error = fmt.Sprintf("only %d of %d poses observed the object", row.Sweep.Count, len(poses))
+	}
+	row.SweepBiasMm = vecNorm(row.Sweep.Mean.Sub(top))
+}
+
+// parseFloatList parses a comma-separated list of numbers, e.g. "1,2,5".
+func parseFloatList(s string) ([]float64, error) {
+	var out []float64
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", field)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
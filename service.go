@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/golang/geo/r3"
 
@@ -24,16 +25,26 @@ type handEyeTest struct {
 
 	arm     arm.Arm
 	camera  camera.Camera
-	gripper gripper.Gripper
+	gripper gripper.Gripper // nil without a gripper
 	motion  motion.Service
 
 	cancelCtx  context.Context
 	cancelFunc func()
 
 	mu            sync.Mutex
-	lastDetection []DetectedObject
+	lastDetection *detectionSnapshot
+	detectionSeq  int
+	objectCounter int
 	currentStatus string
 	lastResult    map[string]interface{}
+	samples       []calibrationSample
+
+	activeOp   *operation
+	jobs       map[string]*job
+	jobOrder   []string
+	jobCounter int
+
+	health healthCache
 }
 
 func newHandEyeTest(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (resource.Resource, error) {
@@ -45,6 +56,7 @@ func newHandEyeTest(ctx context.Context, deps resource.Dependencies, rawConf res
 }
 
 func NewHandEyeTest(ctx context.Context, deps resource.Dependencies, name resource.Name, cfg *Config, logger logging.Logger) (resource.Resource, error) {
+	cfg.applyDefaults()
 	a, err := arm.FromDependencies(deps, cfg.Arm)
 	if err != nil {
 		return nil, fmt.Errorf("getting arm %q: %w", cfg.Arm, err)
@@ -55,9 +67,13 @@ func NewHandEyeTest(ctx context.Context, deps resource.Dependencies, name resour
 		return nil, fmt.Errorf("getting camera %q: %w", cfg.Camera, err)
 	}
 
-	grip, err := gripper.FromDependencies(deps, cfg.Gripper)
-	if err != nil {
-		return nil, fmt.Errorf("getting gripper %q: %w", cfg.Gripper, err)
+	// The gripper is optional: a probe-only tool can still touch objects.
+	var grip gripper.Gripper
+	if cfg.Gripper != "" {
+		grip, err = gripper.FromDependencies(deps, cfg.Gripper)
+		if err != nil {
+			return nil, fmt.Errorf("getting gripper %q: %w", cfg.Gripper, err)
+		}
 	}
 
 	motionSvc, err := motion.FromDependencies(deps, "builtin")
@@ -78,6 +94,7 @@ func NewHandEyeTest(ctx context.Context, deps resource.Dependencies, name resour
 		cancelCtx:     cancelCtx,
 		cancelFunc:    cancelFunc,
 		currentStatus: "idle",
+		jobs:          map[string]*job{},
 	}
 	return s, nil
 }
@@ -94,19 +111,57 @@ func (s *handEyeTest) DoCommand(ctx context.Context, cmd map[string]interface{})
 
 	switch command {
 	case "detect":
-		return s.handleDetect(ctx)
+		sel, err := s.selectionFromCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return s.handleDetect(ctx, sel)
+	case "capture":
+		path, _ := cmd["path"].(string)
+		return s.handleCapture(ctx, path)
 	case "pick":
 		objectIndex := 0
 		if idx, ok := cmd["object_index"].(float64); ok {
 			objectIndex = int(idx)
 		}
-		return s.handlePick(ctx, objectIndex)
+		opts := s.parsePickOptions(cmd)
+		if err := s.requireGripper(command, opts.DryRun); err != nil {
+			return nil, err
+		}
+		sel, err := s.selectionFromCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return s.startJob("pick", func(ctx context.Context) (map[string]interface{}, error) {
+			return s.handlePick(ctx, objectIndex, sel, opts)
+		})
 	case "pick_detected":
 		objectIndex := 0
 		if idx, ok := cmd["object_index"].(float64); ok {
 			objectIndex = int(idx)
 		}
-		return s.handlePickDetected(ctx, objectIndex)
+		objectID := -1
+		if id, ok := cmd["object_id"].(float64); ok {
+			objectID = int(id)
+		}
+		policy := s.cfg.StaleDetection
+		if p, ok := cmd["stale_detection"].(string); ok {
+			if err := validateStaleDetection(p); err != nil {
+				return nil, err
+			}
+			policy = p
+		}
+		opts := s.parsePickOptions(cmd)
+		if err := s.requireGripper(command, opts.DryRun); err != nil {
+			return nil, err
+		}
+		obj, detection, err := s.detectedObject(ctx, objectIndex, objectID, policy)
+		if err != nil {
+			return nil, err
+		}
+		return s.startJob("pick_detected", func(ctx context.Context) (map[string]interface{}, error) {
+			return s.handlePickDetected(ctx, obj, detection, opts)
+		})
 	case "move_to":
 		x, _ := cmd["x"].(float64)
 		y, _ := cmd["y"].(float64)
@@ -115,65 +170,188 @@ func (s *handEyeTest) DoCommand(ctx context.Context, cmd map[string]interface{})
 		if ss, ok := cmd["step_size"].(float64); ok && ss > 0 {
 			stepSize = ss
 		}
-		return s.handleMoveTo(ctx, r3.Vector{X: x, Y: y, Z: z}, stepSize)
+		return s.startJob("move_to", func(ctx context.Context) (map[string]interface{}, error) {
+			return s.handleMoveTo(ctx, r3.Vector{X: x, Y: y, Z: z}, stepSize)
+		})
+	case "touch":
+		objectIndex := 0
+		if idx, ok := cmd["object_index"].(float64); ok {
+			objectIndex = int(idx)
+		}
+		standoff := s.cfg.TouchStandoffMm
+		if so, ok := cmd["standoff_mm"].(float64); ok {
+			if so < 0 {
+				return nil, fmt.Errorf("standoff_mm must not be negative, got %g", so)
+			}
+			standoff = so
+		}
+		sel, err := s.selectionFromCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return s.startJob("touch", func(ctx context.Context) (map[string]interface{}, error) {
+			return s.handleTouch(ctx, objectIndex, sel, standoff)
+		})
+	case "sweep":
+		objectIndex := 0
+		if idx, ok := cmd["object_index"].(float64); ok {
+			objectIndex = int(idx)
+		}
+		sweepCfg, err := parseSweepConfig(cmd, s.cfg.Sweep)
+		if err != nil {
+			return nil, err
+		}
+		sel, err := s.selectionFromCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return s.startJob("sweep", func(ctx context.Context) (map[string]interface{}, error) {
+			return s.handleSweep(ctx, sweepCfg, objectIndex, sel)
+		})
+	case "present":
+		if err := s.requireGripper(command, false); err != nil {
+			return nil, err
+		}
+		presentCfg, err := parsePresentConfig(cmd, s.cfg.Present)
+		if err != nil {
+			return nil, err
+		}
+		return s.startJob("present", func(ctx context.Context) (map[string]interface{}, error) {
+			return s.handlePresent(ctx, presentCfg)
+		})
+	case "benchmark":
+		cycles := 10
+		if c, ok := cmd["cycles"].(float64); ok {
+			cycles = int(c)
+		}
+		objectIndex := 0
+		if idx, ok := cmd["object_index"].(float64); ok {
+			objectIndex = int(idx)
+		}
+		if cycles < 1 {
+			return nil, fmt.Errorf("benchmark needs at least 1 cycle, got %d", cycles)
+		}
+		if err := s.requireGripper(command, false); err != nil {
+			return nil, err
+		}
+		sel, err := s.selectionFromCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return s.startJob("benchmark", func(ctx context.Context) (map[string]interface{}, error) {
+			return s.handleBenchmark(ctx, cycles, objectIndex, sel)
+		})
+	case "cancel":
+		jobID, _ := cmd["job_id"].(string)
+		return s.handleCancel(ctx, jobID)
+	case "history":
+		since, _ := cmd["since"].(string)
+		until, _ := cmd["until"].(string)
+		includeEntries, _ := cmd["include_entries"].(bool)
+		return queryHistory(s.cfg.HistoryFile, since, until, includeEntries)
+	case "health":
+		window := 20
+		if w, ok := cmd["window"].(float64); ok {
+			window = int(w)
+		}
+		return s.health.readings(s.cfg.HistoryFile, window)
+	case "solve_correction":
+		translationOnly, _ := cmd["translation_only"].(bool)
+		clear, _ := cmd["clear"].(bool)
+		return s.handleSolveCorrection(ctx, translationOnly, clear)
 	case "status":
-		return s.handleStatus()
+		jobID, _ := cmd["job_id"].(string)
+		return s.handleStatus(jobID)
 	default:
 		return nil, fmt.Errorf("unknown command: %s", command)
 	}
 }
 
-func (s *handEyeTest) handleDetect(ctx context.Context) (map[string]interface{}, error) {
+// handleDetect detects objects and keeps them for pick_detected. While a job is moving the
+// arm the detection is only reported: the status and the kept detection belong to the job.
+// Otherwise detect holds the operation slot until it finishes, so no job can start and
+// have its status reset by detect's cleanup.
+func (s *handEyeTest) handleDetect(ctx context.Context, sel selection) (map[string]interface{}, error) {
 	s.mu.Lock()
-	s.currentStatus = "detecting"
+	busy := s.checkIdleLocked()
+	if busy != nil && s.cfg.BlockDetectWhileBusy {
+		s.mu.Unlock()
+		return nil, busy
+	}
+	if busy == nil {
+		s.activeOp = &operation{Name: "detect", Started: time.Now()}
+		s.currentStatus = "detecting"
+		defer func() {
+			s.mu.Lock()
+			s.activeOp = nil
+			s.currentStatus = "idle"
+			s.mu.Unlock()
+		}()
+	}
 	s.mu.Unlock()
 
-	objects, err := detectObjects(ctx, s.camera, s.cfg)
+	captured := time.Now()
+	objects, crop, err := s.detectSelected(ctx, sel)
 	if err != nil {
-		s.mu.Lock()
-		s.currentStatus = "idle"
-		s.mu.Unlock()
 		return nil, fmt.Errorf("detection failed: %w", err)
 	}
 
-	s.mu.Lock()
-	s.lastDetection = objects
-	s.currentStatus = "idle"
-	s.mu.Unlock()
+	resp := detectionResponse(objects)
+	if busy == nil {
+		resp = s.storeDetection(ctx, objects, captured).toMap(resp)
+	}
+	resp["selection"] = sel.strategy()
+	if s.cfg.Segmentation.ROI != nil {
+		resp["roi"] = crop.toMap()
+	}
+	return resp, nil
+}
 
-	objList := make([]interface{}, len(objects))
-	for i, obj := range objects {
-		objList[i] = map[string]interface{}{
-			"index":       i,
-			"point_count": obj.PointCount,
-			"center_x_mm": obj.Center.X,
-			"center_y_mm": obj.Center.Y,
-			"center_z_mm": obj.Center.Z,
-		}
+func (s *handEyeTest) handleCapture(ctx context.Context, path string) (map[string]interface{}, error) {
+	if path == "" {
+		return nil, fmt.Errorf("capture requires a 'path' to write the PCD file to")
+	}
+	points, err := capturePointCloud(ctx, s.camera, path)
+	if err != nil {
+		return nil, fmt.Errorf("capture failed: %w", err)
 	}
+	s.logger.Infof("Saved %d-point cloud to %s", points, path)
+	return map[string]interface{}{"path": path, "point_count": points}, nil
+}
+
+// requireGripper refuses commands that grasp when the service has no gripper. A dry run
+// only plans, so it is allowed.
+func (s *handEyeTest) requireGripper(command string, dryRun bool) error {
+	if s.gripper == nil && !dryRun {
+		return fmt.Errorf("%s needs a gripper; without one, use touch", command)
+	}
+	return nil
+}
 
-	return map[string]interface{}{
-		"objects": objList,
-		"count":   len(objects),
-	}, nil
+// parsePickOptions reads per-command pick switches, falling back to the service config.
+func (s *handEyeTest) parsePickOptions(cmd map[string]interface{}) pickOptions {
+	opts := pickOptions{Place: s.cfg.Place.Enabled}
+	if place, ok := cmd["place"].(bool); ok {
+		opts.Place = place
+	}
+	opts.DryRun, _ = cmd["dry_run"].(bool)
+	return opts
 }
 
-func (s *handEyeTest) handlePick(ctx context.Context, objectIndex int) (map[string]interface{}, error) {
+func (s *handEyeTest) handlePick(ctx context.Context, objectIndex int, sel selection, opts pickOptions) (map[string]interface{}, error) {
 	s.mu.Lock()
 	s.currentStatus = "detecting"
 	s.mu.Unlock()
 
-	objects, err := detectObjects(ctx, s.camera, s.cfg)
+	captured := time.Now()
+	objects, _, err := s.detectSelected(ctx, sel)
 	if err != nil {
 		s.mu.Lock()
 		s.currentStatus = "idle"
 		s.mu.Unlock()
 		return nil, fmt.Errorf("detection failed: %w", err)
 	}
-
-	s.mu.Lock()
-	s.lastDetection = objects
-	s.mu.Unlock()
+	s.storeDetection(ctx, objects, captured)
 
 	if objectIndex >= len(objects) {
 		s.mu.Lock()
@@ -182,47 +360,86 @@ func (s *handEyeTest) handlePick(ctx context.Context, objectIndex int) (map[stri
 		return nil, fmt.Errorf("object_index %d out of range (detected %d objects)", objectIndex, len(objects))
 	}
 
-	result, err := s.executePick(ctx, objects[objectIndex])
+	if opts.DryRun {
+		return s.handleDryRun(ctx, objects[objectIndex])
+	}
+	res, err := s.executePick(ctx, objects[objectIndex], opts)
+	var result map[string]interface{}
+	if res != nil {
+		result = res.toMap()
+	}
 	s.mu.Lock()
 	s.currentStatus = "idle"
 	s.lastResult = result
 	s.mu.Unlock()
+	s.recordHistory("pick", result)
 	return result, err
 }
 
-func (s *handEyeTest) handlePickDetected(ctx context.Context, objectIndex int) (map[string]interface{}, error) {
-	s.mu.Lock()
-	objects := s.lastDetection
-	s.mu.Unlock()
-
-	if objects == nil {
-		return nil, fmt.Errorf("no previous detection; run 'detect' first")
+// handlePickDetected picks an object from the last detection. detection describes that
+// detection and is added to the result.
+func (s *handEyeTest) handlePickDetected(
+	ctx context.Context, obj DetectedObject, detection map[string]interface{}, opts pickOptions,
+) (map[string]interface{}, error) {
+	if opts.DryRun {
+		result, err := s.handleDryRun(ctx, obj)
+		if result != nil {
+			result["detection"] = detection
+		}
+		return result, err
 	}
-	if objectIndex >= len(objects) {
-		return nil, fmt.Errorf("object_index %d out of range (detected %d objects)", objectIndex, len(objects))
+	res, err := s.executePick(ctx, obj, opts)
+	var result map[string]interface{}
+	if res != nil {
+		result = res.toMap()
+		result["detection"] = detection
 	}
-
-	result, err := s.executePick(ctx, objects[objectIndex])
 	s.mu.Lock()
 	s.currentStatus = "idle"
 	s.lastResult = result
 	s.mu.Unlock()
+	s.recordHistory("pick_detected", result)
+	return result, err
+}
+
+// handleDryRun plans a pick without moving. Its result is kept as the last result but not
+// recorded to history, since nothing was measured.
+func (s *handEyeTest) handleDryRun(ctx context.Context, obj DetectedObject) (map[string]interface{}, error) {
+	result, err := s.planPick(ctx, obj)
+	s.mu.Lock()
+	s.currentStatus = "idle"
+	if result != nil {
+		s.lastResult = result
+	}
+	s.mu.Unlock()
 	return result, err
 }
 
-func (s *handEyeTest) handleStatus() (map[string]interface{}, error) {
+func (s *handEyeTest) handleStatus(jobID string) (map[string]interface{}, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	result := map[string]interface{}{
 		"status": s.currentStatus,
 	}
+	if s.activeOp != nil {
+		result["operation"] = s.activeOp.toMap()
+	}
+	j, err := s.lookupJobLocked(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if j != nil {
+		result["job"] = j.toMap()
+	}
 	if s.lastResult != nil {
 		result["last_result"] = s.lastResult
 	}
 	if s.lastDetection != nil {
-		result["detected_objects"] = len(s.lastDetection)
+		result["detected_objects"] = len(s.lastDetection.Objects)
+		result["detection_age_sec"] = time.Since(s.lastDetection.Time).Seconds()
 	}
+	result["calibration_samples"] = len(s.samples)
 	return result, nil
 }
 
@@ -0,0 +1,222 @@
+package handeyetest
+
+import (
+	"math"
+
+	"github.com/golang/geo/r3"
+	"gonum.org/v1/gonum/mat"
+
+	pc "go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// groundPlane is the table plane found during segmentation, Normal·p + Offset = 0, with a
+// unit Normal oriented toward the side the objects are on.
+type groundPlane struct {
+	Normal r3.Vector
+	Offset float64
+}
+
+func newGroundPlane(p pc.Plane) *groundPlane {
+	eq := p.Equation()
+	n := r3.Vector{X: eq[0], Y: eq[1], Z: eq[2]}
+	norm := n.Norm()
+	if norm == 0 {
+		return nil
+	}
+	return &groundPlane{Normal: n.Mul(1 / norm), Offset: eq[3] / norm}
+}
+
+// refineGroundPlane fits a plane to a RANSAC plane's points by least squares. RANSAC
+// keeps the plane through the three points that gather the most inliers, which within the
+// distance threshold can tilt a degree or so, enough to matter far across the table.
+func refineGroundPlane(p pc.Plane) (*groundPlane, error) {
+	cloud, err := p.PointCloud()
+	if err != nil {
+		return nil, err
+	}
+	points := clusterPoints(cloud)
+	if len(points) < 3 {
+		return newGroundPlane(p), nil
+	}
+	mean := computeCenter(cloud)
+	normal := principalAxes(points, mean)[2]
+	return &groundPlane{Normal: normal, Offset: -normal.Dot(mean)}, nil
+}
+
+// signedDistance is positive on the side the normal points to.
+func (gp *groundPlane) signedDistance(p r3.Vector) float64 {
+	return gp.Normal.Dot(p) + gp.Offset
+}
+
+func (gp *groundPlane) toMap() map[string]interface{} {
+	return map[string]interface{}{"normal": vectorToMap(gp.Normal), "offset_mm": gp.Offset}
+}
+
+// orientToward flips the plane so that the given point lies on its positive side.
+func (gp *groundPlane) orientToward(p r3.Vector) {
+	if gp.signedDistance(p) < 0 {
+		gp.Normal = gp.Normal.Mul(-1)
+		gp.Offset = -gp.Offset
+	}
+}
+
+// BoundingBox is an axis-aligned box in the detection frame.
+type BoundingBox struct {
+	Min r3.Vector
+	Max r3.Vector
+}
+
+// OrientedBox is a box aligned with a cluster's principal axes. Axes are unit vectors
+// ordered from major (largest spread) to minor, and form a right-handed frame; Dims are
+// the box's full extents along each axis.
+type OrientedBox struct {
+	Center r3.Vector
+	Axes   [3]r3.Vector
+	Dims   [3]float64
+}
+
+// clusterPoints returns every point in a cloud.
+func clusterPoints(cloud pc.PointCloud) []r3.Vector {
+	points := make([]r3.Vector, 0, cloud.Size())
+	cloud.Iterate(0, 0, func(p r3.Vector, _ pc.Data) bool {
+		points = append(points, p)
+		return true
+	})
+	return points
+}
+
+// computeBoundingBox returns the axis-aligned bounds of the points.
+func computeBoundingBox(points []r3.Vector) BoundingBox {
+	if len(points) == 0 {
+		return BoundingBox{}
+	}
+	box := BoundingBox{Min: points[0], Max: points[0]}
+	for _, p := range points[1:] {
+		box.Min = r3.Vector{X: math.Min(box.Min.X, p.X), Y: math.Min(box.Min.Y, p.Y), Z: math.Min(box.Min.Z, p.Z)}
+		box.Max = r3.Vector{X: math.Max(box.Max.X, p.X), Y: math.Max(box.Max.Y, p.Y), Z: math.Max(box.Max.Z, p.Z)}
+	}
+	return box
+}
+
+// principalAxes runs PCA on the points around their mean and returns the eigenvectors of
+// the covariance matrix ordered by decreasing variance, as a right-handed frame.
+func principalAxes(points []r3.Vector, mean r3.Vector) [3]r3.Vector {
+	axes := [3]r3.Vector{{X: 1}, {Y: 1}, {Z: 1}}
+	if len(points) < 3 {
+		return axes
+	}
+	cov := mat.NewSymDense(3, nil)
+	for _, p := range points {
+		d := p.Sub(mean)
+		v := [3]float64{d.X, d.Y, d.Z}
+		for i := 0; i < 3; i++ {
+			for j := i; j < 3; j++ {
+				cov.SetSym(i, j, cov.At(i, j)+v[i]*v[j])
+			}
+		}
+	}
+
+	var eig mat.EigenSym
+	if !eig.Factorize(cov, true) {
+		return axes
+	}
+	var vecs mat.Dense
+	eig.VectorsTo(&vecs)
+	// Eigenvalues come back in ascending order; the major axis is the last column.
+	for i := 0; i < 3; i++ {
+		col := 2 - i
+		axes[i] = r3.Vector{X: vecs.At(0, col), Y: vecs.At(1, col), Z: vecs.At(2, col)}.Normalize()
+	}
+	axes[2] = axes[0].Cross(axes[1]).Normalize()
+	return axes
+}
+
+// computeOrientedBox fits a box aligned with the points' principal axes.
+func computeOrientedBox(points []r3.Vector, mean r3.Vector) OrientedBox {
+	axes := principalAxes(points, mean)
+	box := OrientedBox{Axes: axes, Center: mean}
+	if len(points) == 0 {
+		return box
+	}
+	var lo, hi [3]float64
+	for i := range lo {
+		lo[i], hi[i] = math.Inf(1), math.Inf(-1)
+	}
+	for _, p := range points {
+		d := p.Sub(mean)
+		for i, axis := range axes {
+			proj := d.Dot(axis)
+			lo[i] = math.Min(lo[i], proj)
+			hi[i] = math.Max(hi[i], proj)
+		}
+	}
+	for i, axis := range axes {
+		box.Dims[i] = hi[i] - lo[i]
+		box.Center = box.Center.Add(axis.Mul((hi[i] + lo[i]) / 2))
+	}
+	return box
+}
+
+// transform returns the box expressed in the parent frame of the given pose.
+func (ob OrientedBox) transform(pose spatialmath.Pose) OrientedBox {
+	out := OrientedBox{
+		Center: spatialmath.Compose(pose, spatialmath.NewPoseFromPoint(ob.Center)).Point(),
+		Dims:   ob.Dims,
+	}
+	for i, axis := range ob.Axes {
+		out.Axes[i] = rotate(pose.Orientation(), axis)
+	}
+	return out
+}
+
+// rotate applies an orientation to a direction. (RotationMatrix.Mul applies the inverse.)
+func rotate(o spatialmath.Orientation, v r3.Vector) r3.Vector {
+	return spatialmath.Compose(spatialmath.NewPoseFromOrientation(o), spatialmath.NewPoseFromPoint(v)).Point()
+}
+
+// heightAbovePlane returns how far the cluster's highest point rises above the plane.
+func heightAbovePlane(points []r3.Vector, plane *groundPlane) float64 {
+	if plane == nil {
+		return 0
+	}
+	height := 0.0
+	for _, p := range points {
+		height = math.Max(height, plane.signedDistance(p))
+	}
+	return height
+}
+
+func vectorToMap(v r3.Vector) map[string]interface{} {
+	return map[string]interface{}{"x": v.X, "y": v.Y, "z": v.Z}
+}
+
+func orientationToMap(o spatialmath.Orientation) map[string]interface{} {
+	ov := o.OrientationVectorDegrees()
+	return map[string]interface{}{"o_x": ov.OX, "o_y": ov.OY, "o_z": ov.OZ, "theta": ov.Theta}
+}
+
+func positionToMap(v r3.Vector) map[string]interface{} {
+	return map[string]interface{}{"x_mm": v.X, "y_mm": v.Y, "z_mm": v.Z}
+}
+
+func (bb BoundingBox) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"min": positionToMap(bb.Min),
+		"max": positionToMap(bb.Max),
+	}
+}
+
+func (ob OrientedBox) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"center": positionToMap(ob.Center),
+		"dims_mm": map[string]interface{}{
+			"major": ob.Dims[0], "middle": ob.Dims[1], "minor": ob.Dims[2],
+		},
+		"axes": map[string]interface{}{
+			"major":  vectorToMap(ob.Axes[0]),
+			"middle": vectorToMap(ob.Axes[1]),
+			"minor":  vectorToMap(ob.Axes[2]),
+		},
+	}
+}
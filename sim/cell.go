@@ -0,0 +1,514 @@
+// Package sim is an in-process simulated work cell for running the hand-eye tester without
+// a robot: a table with boxes and cylinders, a kinematic arm carrying a gripper and a
+// depth camera, and a motion service whose frame system can carry a deliberate camera
+// calibration error.
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/golang/geo/r3"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	pb "go.viam.com/api/service/motion/v1"
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/gripper"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	injectmotion "go.viam.com/rdk/testutils/inject/motion"
+	"go.viam.com/rdk/utils"
+)
+
+// Shape is the kind of a simulated object.
+type Shape string
+
+// Supported object shapes.
+const (
+	Box      Shape = "box"
+	Cylinder Shape = "cylinder"
+)
+
+// Object is a rigid object standing on the table.
+type Object struct {
+	Shape Shape
+	// Position is the world position of the center of the object's base.
+	Position r3.Vector
+	// Size is the box extents along its own X, Y and Z, or for a cylinder its diameter (X)
+	// and height (Z).
+	Size   r3.Vector
+	YawDeg float64
+	// Marker, if set, is printed on the center of the object's top face.
+	Marker *Marker
+	// Checkerboard, if set, is printed on the center of the object's top face.
+	Checkerboard *Checkerboard
+}
+
+// Marker is a marker from the original ArUco dictionary, with its top edge toward the
+// object's +Y axis. SizeMm is the side of its black square.
+type Marker struct {
+	ID     int
+	SizeMm float64
+}
+
+// Checkerboard is a calibration board with InnerCols by InnerRows inner corners, so one
+// more square than that each way. Its squares alternate from a black one at the corner
+// toward the object's -X and +Y.
+type Checkerboard struct {
+	InnerCols int
+	InnerRows int
+	SquareMm  float64
+}
+
+// Top returns the world position of the center of the object's top face.
+func (o Object) Top() r3.Vector {
+	return r3.Vector{X: o.Position.X, Y: o.Position.Y, Z: o.Position.Z + o.Size.Z}
+}
+
+// Config describes the cell. Poses are in millimetres.
+type Config struct {
+	Arm     string
+	Camera  string
+	Gripper string
+
+	// The table top is the plane Z = TableZ in world, bounded by TableMin/TableMax in X and Y.
+	TableZ   float64
+	TableMin r3.Vector
+	TableMax r3.Vector
+	Objects  []Object
+
+	// ArmBase is the arm base in world; Home is the starting arm end pose in the base frame.
+	ArmBase spatialmath.Pose
+	Home    spatialmath.Pose
+	// GripperOffset is the gripper's grasp point in the arm end frame.
+	GripperOffset spatialmath.Pose
+	// CameraMount is the true camera pose in the arm end frame, or in world if
+	// CameraStatic is set (an eye-to-hand camera that does not move with the arm).
+	CameraMount  spatialmath.Pose
+	CameraStatic bool
+	// CloudInWorld has the camera return point clouds in world, placed through the camera
+	// pose the motion service reports, like a camera behind a frame-transforming source.
+	CloudInWorld bool
+	// CalibrationError is applied, in the camera frame, to the camera mount the motion
+	// service reports. The camera itself keeps rendering from the true mount.
+	CalibrationError spatialmath.Pose
+
+	// ReachMm limits how far from the arm base the arm end can go.
+	ReachMm float64
+	// GraspToleranceMm is how far off the object's axis the gripper can close and still
+	// hold it.
+	GraspToleranceMm float64
+
+	// ImageWidth x ImageHeight rays are cast over a horizontal field of view of FOVDeg.
+	ImageWidth  int
+	ImageHeight int
+	FOVDeg      float64
+	// DepthNoiseMm is the standard deviation of noise added along each ray.
+	DepthNoiseMm float64
+}
+
+// DefaultConfig returns a cell with a 40mm cube on the table below an arm that points
+// straight down. The camera looks along the gripper axis, which keeps the object centered
+// in view throughout a pick.
+func DefaultConfig() Config {
+	return Config{
+		Arm:              "arm",
+		Camera:           "camera",
+		Gripper:          "gripper",
+		TableMin:         r3.Vector{X: 100, Y: -400},
+		TableMax:         r3.Vector{X: 900, Y: 400},
+		Objects:          []Object{{Shape: Box, Position: r3.Vector{X: 450}, Size: r3.Vector{X: 40, Y: 40, Z: 40}}},
+		ArmBase:          spatialmath.NewZeroPose(),
+		Home:             spatialmath.NewPose(r3.Vector{X: 450, Z: 500}, &spatialmath.OrientationVectorDegrees{OZ: -1}),
+		GripperOffset:    spatialmath.NewPoseFromPoint(r3.Vector{Z: 150}),
+		CameraMount:      spatialmath.NewZeroPose(),
+		CalibrationError: spatialmath.NewZeroPose(),
+		ReachMm:          1000,
+		GraspToleranceMm: 10,
+		ImageWidth:       240,
+		ImageHeight:      180,
+		FOVDeg:           70,
+	}
+}
+
+// StaticCameraConfig returns DefaultConfig with the camera fixed in the cell instead of
+// riding on the arm: above and behind the cube, looking at it 15 degrees off vertical, so
+// that the camera's Z axis is not the way down to the table.
+func StaticCameraConfig() Config {
+	cfg := DefaultConfig()
+	cfg.CameraMount = spatialmath.NewPose(r3.Vector{X: 333.5, Z: 474.7}, &spatialmath.OrientationVectorDegrees{OX: 116.5, OZ: -434.7})
+	cfg.CameraStatic = true
+	return cfg
+}
+
+// Cell is a running simulation. Its components are safe for concurrent use.
+type Cell struct {
+	mu      sync.Mutex
+	cfg     Config
+	end     spatialmath.Pose
+	objects []Object
+	// held is the index of the object in the gripper, or -1; heldOffset is its base
+	// position relative to the gripper while held.
+	held       int
+	heldOffset r3.Vector
+	rng        *rand.Rand
+
+	arm     *inject.Arm
+	camera  *inject.Camera
+	gripper *inject.Gripper
+	motion  *injectmotion.MotionService
+}
+
+// NewCell builds a cell from a config.
+func NewCell(cfg Config) *Cell {
+	c := &Cell{
+		cfg:     cfg,
+		end:     cfg.Home,
+		objects: append([]Object{}, cfg.Objects...),
+		held:    -1,
+		rng:     rand.New(rand.NewSource(1)),
+	}
+	c.arm = c.newArm()
+	c.camera = c.newCamera()
+	c.gripper = c.newGripper()
+	c.motion = c.newMotion()
+	return c
+}
+
+// Dependencies returns the cell's arm, camera, gripper and "builtin" motion service.
+func (c *Cell) Dependencies() resource.Dependencies {
+	return resource.Dependencies{
+		arm.Named(c.cfg.Arm):         c.arm,
+		camera.Named(c.cfg.Camera):   c.camera,
+		gripper.Named(c.cfg.Gripper): c.gripper,
+		motion.Named("builtin"):      c.motion,
+	}
+}
+
+// SetCalibrationError changes the error on the reported camera mount.
+func (c *Cell) SetCalibrationError(err spatialmath.Pose) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg.CalibrationError = err
+}
+
+// TrueFramePose returns the actual world pose of "world" or the arm, gripper or camera.
+func (c *Cell) TrueFramePose(frame string) (spatialmath.Pose, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.framePoseLocked(frame, false)
+}
+
+// ReportedFramePose returns the world pose of a frame as the motion service reports it,
+// including the calibration error for the camera.
+func (c *Cell) ReportedFramePose(frame string) (spatialmath.Pose, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.framePoseLocked(frame, true)
+}
+
+// Objects returns the objects as they currently are, including one held in the gripper.
+func (c *Cell) Objects() []Object {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	objects := append([]Object{}, c.objects...)
+	if c.held >= 0 {
+		objects[c.held] = c.heldObjectLocked()
+	}
+	return objects
+}
+
+// Holding reports whether the gripper holds an object.
+func (c *Cell) Holding() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.held >= 0
+}
+
+// ApparentError is how far the reported camera pose misplaces a world point seen from
+// where the arm is now.
+func (c *Cell) ApparentError(p r3.Vector) r3.Vector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	truePose, _ := c.framePoseLocked(c.cfg.Camera, false)
+	reported, _ := c.framePoseLocked(c.cfg.Camera, true)
+	inCamera := spatialmath.Compose(spatialmath.PoseInverse(truePose), spatialmath.NewPoseFromPoint(p))
+	return spatialmath.Compose(reported, inCamera).Point().Sub(p)
+}
+
+// MoveObject puts an object down at a new X/Y position on the table.
+func (c *Cell) MoveObject(i int, x, y float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i < 0 || i >= len(c.objects) {
+		return fmt.Errorf("object index %d out of range (%d objects)", i, len(c.objects))
+	}
+	if i == c.held {
+		c.held = -1
+	}
+	c.objects[i].Position = r3.Vector{X: x, Y: y, Z: c.cfg.TableZ}
+	return nil
+}
+
+// ResetArm drops anything held where it is and returns the arm to its home pose.
+func (c *Cell) ResetArm() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.held >= 0 {
+		obj := c.heldObjectLocked()
+		obj.Position.Z = c.cfg.TableZ
+		c.objects[c.held] = obj
+		c.held = -1
+	}
+	c.end = c.cfg.Home
+}
+
+func (c *Cell) framePoseLocked(frame string, reported bool) (spatialmath.Pose, error) {
+	end := spatialmath.Compose(c.cfg.ArmBase, c.end)
+	switch frame {
+	case referenceframe.World:
+		return spatialmath.NewZeroPose(), nil
+	case c.cfg.Arm:
+		return end, nil
+	case c.cfg.Gripper:
+		return spatialmath.Compose(end, c.cfg.GripperOffset), nil
+	case c.cfg.Camera:
+		mount := c.cfg.CameraMount
+		if reported {
+			mount = spatialmath.Compose(mount, c.cfg.CalibrationError)
+		}
+		if c.cfg.CameraStatic {
+			return mount, nil
+		}
+		return spatialmath.Compose(end, mount), nil
+	default:
+		return nil, fmt.Errorf("unknown frame %q", frame)
+	}
+}
+
+func (c *Cell) heldObjectLocked() Object {
+	obj := c.objects[c.held]
+	tcp := spatialmath.Compose(spatialmath.Compose(c.cfg.ArmBase, c.end), c.cfg.GripperOffset).Point()
+	obj.Position = tcp.Add(c.heldOffset)
+	return obj
+}
+
+// setEndLocked moves the arm end to a pose in the base frame if it is reachable and keeps
+// the gripper above the table.
+func (c *Cell) setEndLocked(end spatialmath.Pose) error {
+	if end.Point().Norm() > c.cfg.ReachMm {
+		return fmt.Errorf("pose (%.1f, %.1f, %.1f) is out of reach", end.Point().X, end.Point().Y, end.Point().Z)
+	}
+	tcp := spatialmath.Compose(spatialmath.Compose(c.cfg.ArmBase, end), c.cfg.GripperOffset).Point()
+	if tcp.Z < c.cfg.TableZ {
+		return fmt.Errorf("gripper would hit the table at z=%.1f", tcp.Z)
+	}
+	c.end = end
+	return nil
+}
+
+func (c *Cell) newArm() *inject.Arm {
+	a := inject.NewArm(c.cfg.Arm)
+	a.EndPositionFunc = func(ctx context.Context, extra map[string]interface{}) (spatialmath.Pose, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.end, nil
+	}
+	a.MoveToPositionFunc = func(ctx context.Context, to spatialmath.Pose, extra map[string]interface{}) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.setEndLocked(to)
+	}
+	a.StopFunc = func(ctx context.Context, extra map[string]interface{}) error { return nil }
+	a.IsMovingFunc = func(context.Context) (bool, error) { return false, nil }
+	return a
+}
+
+func (c *Cell) newCamera() *inject.Camera {
+	cam := inject.NewCamera(c.cfg.Camera)
+	cam.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		cloud, err := c.renderLocked()
+		if err != nil || !c.cfg.CloudInWorld {
+			return cloud, err
+		}
+		camPose, err := c.framePoseLocked(c.cfg.Camera, true)
+		if err != nil {
+			return nil, err
+		}
+		world := pointcloud.NewBasicEmpty()
+		cloud.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			err = world.Set(spatialmath.Compose(camPose, spatialmath.NewPoseFromPoint(p)).Point(), d)
+			return err == nil
+		})
+		return world, err
+	}
+	cam.ImagesFunc = func(
+		ctx context.Context, filterSourceNames []string, extra map[string]interface{},
+	) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		c.mu.Lock()
+		img, err := c.renderImageLocked()
+		c.mu.Unlock()
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		named, err := camera.NamedImageFromImage(img, "color", utils.MimeTypePNG, data.Annotations{})
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		return []camera.NamedImage{named}, resource.ResponseMetadata{}, nil
+	}
+	cam.PropertiesFunc = func(ctx context.Context) (camera.Properties, error) {
+		w, h := c.cfg.ImageWidth, c.cfg.ImageHeight
+		focal := c.focalLength()
+		return camera.Properties{
+			SupportsPCD: true,
+			ImageType:   camera.ColorStream,
+			// Pixel centers are at integer coordinates.
+			IntrinsicParams: &transform.PinholeCameraIntrinsics{
+				Width: w, Height: h, Fx: focal, Fy: focal, Ppx: float64(w)/2 - 0.5, Ppy: float64(h)/2 - 0.5,
+			},
+		}, nil
+	}
+	return cam
+}
+
+func (c *Cell) newGripper() *inject.Gripper {
+	g := inject.NewGripper(c.cfg.Gripper)
+	g.OpenFunc = func(ctx context.Context, extra map[string]interface{}) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.held >= 0 {
+			// The released object drops straight down onto the table.
+			obj := c.heldObjectLocked()
+			obj.Position.Z = c.cfg.TableZ
+			c.objects[c.held] = obj
+			c.held = -1
+		}
+		return nil
+	}
+	g.GrabFunc = func(ctx context.Context, extra map[string]interface{}) (bool, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.held >= 0 {
+			return true, nil
+		}
+		tcp := spatialmath.Compose(spatialmath.Compose(c.cfg.ArmBase, c.end), c.cfg.GripperOffset).Point()
+		for i, obj := range c.objects {
+			horizontal := math.Hypot(tcp.X-obj.Position.X, tcp.Y-obj.Position.Y)
+			if horizontal <= c.cfg.GraspToleranceMm && tcp.Z >= obj.Position.Z && tcp.Z < obj.Top().Z {
+				// Closing jaws pull the object onto the gripper axis.
+				c.held = i
+				c.heldOffset = r3.Vector{Z: obj.Position.Z - tcp.Z}
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	g.IsHoldingSomethingFunc = func(ctx context.Context, extra map[string]interface{}) (gripper.HoldingStatus, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return gripper.HoldingStatus{IsHoldingSomething: c.held >= 0}, nil
+	}
+	g.StopFunc = func(ctx context.Context, extra map[string]interface{}) error { return nil }
+	return g
+}
+
+// endForDestination returns the arm end pose, in the base frame, that puts a component at
+// a destination, resolving frames the way the motion service reports them.
+func (c *Cell) endForDestination(component string, dest *referenceframe.PoseInFrame) (spatialmath.Pose, error) {
+	frame, err := c.framePoseLocked(dest.Parent(), true)
+	if err != nil {
+		return nil, err
+	}
+	target := spatialmath.Compose(frame, dest.Pose())
+	var endWorld spatialmath.Pose
+	switch component {
+	case c.cfg.Arm:
+		endWorld = target
+	case c.cfg.Gripper:
+		endWorld = spatialmath.Compose(target, spatialmath.PoseInverse(c.cfg.GripperOffset))
+	case c.cfg.Camera:
+		if c.cfg.CameraStatic {
+			return nil, fmt.Errorf("cannot move static camera %q", component)
+		}
+		mount := spatialmath.Compose(c.cfg.CameraMount, c.cfg.CalibrationError)
+		endWorld = spatialmath.Compose(target, spatialmath.PoseInverse(mount))
+	default:
+		return nil, fmt.Errorf("cannot move %q", component)
+	}
+	return spatialmath.Compose(spatialmath.PoseInverse(c.cfg.ArmBase), endWorld), nil
+}
+
+func (c *Cell) newMotion() *injectmotion.MotionService {
+	m := injectmotion.NewMotionService("builtin")
+	m.GetPoseFunc = func(
+		ctx context.Context, componentName, destinationFrame string,
+		supplementalTransforms []*referenceframe.LinkInFrame, extra map[string]interface{},
+	) (*referenceframe.PoseInFrame, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		pose, err := c.framePoseLocked(componentName, true)
+		if err != nil {
+			return nil, err
+		}
+		dest, err := c.framePoseLocked(destinationFrame, true)
+		if err != nil {
+			return nil, err
+		}
+		return referenceframe.NewPoseInFrame(destinationFrame, spatialmath.Compose(spatialmath.PoseInverse(dest), pose)), nil
+	}
+	m.MoveFunc = func(ctx context.Context, req motion.MoveReq) (bool, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		end, err := c.endForDestination(req.ComponentName, req.Destination)
+		if err != nil {
+			return false, err
+		}
+		if err := c.setEndLocked(end); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	m.DoCommandFunc = func(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+		reqJSON, ok := cmd["plan"].(string)
+		if !ok {
+			return nil, fmt.Errorf("unsupported command")
+		}
+		var reqPB pb.MoveRequest
+		if err := protojson.Unmarshal([]byte(reqJSON), &reqPB); err != nil {
+			return nil, err
+		}
+		req, err := motion.MoveReqFromProto(&reqPB)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		end, err := c.endForDestination(req.ComponentName, req.Destination)
+		if err != nil {
+			return nil, err
+		}
+		// Plan without moving: check the goal the same way a move would, then restore.
+		start := c.end
+		if err := c.setEndLocked(end); err != nil {
+			return nil, err
+		}
+		c.end = start
+		return map[string]interface{}{"plan": []interface{}{
+			spatialmath.PoseToProtobuf(start), spatialmath.PoseToProtobuf(end),
+		}}, nil
+	}
+	return m
+}
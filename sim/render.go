@@ -0,0 +1,257 @@
+package sim
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// renderLocked casts one ray per pixel from the true camera pose and returns the nearest
+// hits as a point cloud in the camera frame (Z forward, X right, Y down).
+func (c *Cell) renderLocked() (pointcloud.PointCloud, error) {
+	camPose, err := c.framePoseLocked(c.cfg.Camera, false)
+	if err != nil {
+		return nil, err
+	}
+	objects := append([]Object{}, c.objects...)
+	if c.held >= 0 {
+		objects[c.held] = c.heldObjectLocked()
+	}
+
+	origin := camPose.Point()
+	toCamera := spatialmath.PoseInverse(camPose)
+
+	cloud := pointcloud.NewBasicEmpty()
+	for v := 0; v < c.cfg.ImageHeight; v++ {
+		for u := 0; u < c.cfg.ImageWidth; u++ {
+			dir := c.pixelRay(camPose, u, v)
+			t, _, ok := c.castLocked(origin, dir, objects)
+			if !ok {
+				continue
+			}
+			if c.cfg.DepthNoiseMm > 0 {
+				t += c.rng.NormFloat64() * c.cfg.DepthNoiseMm
+			}
+			world := origin.Add(dir.Mul(t))
+			p := spatialmath.Compose(toCamera, spatialmath.NewPoseFromPoint(world)).Point()
+			if err := cloud.Set(p, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return cloud, nil
+}
+
+// Gray levels of the rendered color image.
+const (
+	grayNothing = 60
+	grayTable   = 110
+	graySide    = 170
+	grayWhite   = 225
+	grayBlack   = 20
+)
+
+// renderImageLocked casts the same rays as renderLocked and shades what they hit: the
+// table, the objects' sides, and their white top faces with any markers printed on them.
+func (c *Cell) renderImageLocked() (*image.Gray, error) {
+	camPose, err := c.framePoseLocked(c.cfg.Camera, false)
+	if err != nil {
+		return nil, err
+	}
+	objects := append([]Object{}, c.objects...)
+	if c.held >= 0 {
+		objects[c.held] = c.heldObjectLocked()
+	}
+
+	origin := camPose.Point()
+	img := image.NewGray(image.Rect(0, 0, c.cfg.ImageWidth, c.cfg.ImageHeight))
+	for v := 0; v < c.cfg.ImageHeight; v++ {
+		for u := 0; u < c.cfg.ImageWidth; u++ {
+			dir := c.pixelRay(camPose, u, v)
+			shade := uint8(grayNothing)
+			if t, hit, ok := c.castLocked(origin, dir, objects); ok {
+				shade = grayTable
+				if hit >= 0 {
+					shade = surfaceShade(objects[hit], origin.Add(dir.Mul(t)))
+				}
+			}
+			img.SetGray(u, v, color.Gray{Y: shade})
+		}
+	}
+	return img, nil
+}
+
+// surfaceShade is the gray level of an object's surface at a world point.
+func surfaceShade(obj Object, p r3.Vector) uint8 {
+	local := toObjectFrame(obj, p.Sub(obj.Position))
+	if local.Z < obj.Size.Z-1e-6 {
+		return graySide
+	}
+	if obj.Checkerboard != nil {
+		return checkerboardShade(*obj.Checkerboard, local)
+	}
+	if obj.Marker == nil {
+		return grayWhite
+	}
+	half := obj.Marker.SizeMm / 2
+	if math.Abs(local.X) >= half || math.Abs(local.Y) >= half {
+		return grayWhite
+	}
+	cell := obj.Marker.SizeMm / 7
+	col := int((local.X + half) / cell)
+	row := int((half - local.Y) / cell)
+	if markerCellWhite(obj.Marker.ID, min(row, 6), min(col, 6)) {
+		return grayWhite
+	}
+	return grayBlack
+}
+
+// checkerboardShade is the gray level of a checkerboard at a point on its face, in the
+// object's frame.
+func checkerboardShade(board Checkerboard, local r3.Vector) uint8 {
+	halfW := float64(board.InnerCols+1) / 2 * board.SquareMm
+	halfH := float64(board.InnerRows+1) / 2 * board.SquareMm
+	if math.Abs(local.X) >= halfW || math.Abs(local.Y) >= halfH {
+		return grayWhite
+	}
+	col := int((local.X + halfW) / board.SquareMm)
+	row := int((halfH - local.Y) / board.SquareMm)
+	if (col+row)%2 == 0 {
+		return grayBlack
+	}
+	return grayWhite
+}
+
+// markerCellWhite reports whether a cell of an original ArUco marker is white. The outer
+// ring of the 7x7 cells is the black border; each of the five data rows shows two bits
+// of the ID, most significant row first, as one of four 5-bit words.
+func markerCellWhite(id, row, col int) bool {
+	if row == 0 || col == 0 || row == 6 || col == 6 {
+		return false
+	}
+	words := [4]int{0x10, 0x17, 0x09, 0x0e}
+	word := words[(id>>(2*(5-row)))&3]
+	return (word>>(5-col))&1 == 1
+}
+
+// focalLength is the camera's focal length in pixels.
+func (c *Cell) focalLength() float64 {
+	return float64(c.cfg.ImageWidth) / 2 / math.Tan(c.cfg.FOVDeg*math.Pi/360)
+}
+
+// pixelRay is the world direction of the ray through the center of a pixel.
+func (c *Cell) pixelRay(camPose spatialmath.Pose, u, v int) r3.Vector {
+	focal := c.focalLength()
+	ray := r3.Vector{
+		X: (float64(u) + 0.5 - float64(c.cfg.ImageWidth)/2) / focal,
+		Y: (float64(v) + 0.5 - float64(c.cfg.ImageHeight)/2) / focal,
+		Z: 1,
+	}.Normalize()
+	// RotationMatrix.Mul applies the inverse rotation; the matrix's rows are the camera
+	// axes in world.
+	rot := camPose.Orientation().RotationMatrix()
+	return rot.Row(0).Mul(ray.X).Add(rot.Row(1).Mul(ray.Y)).Add(rot.Row(2).Mul(ray.Z))
+}
+
+// castLocked returns the distance along the ray to the nearest surface, and the index of
+// the object hit (-1 for the table).
+func (c *Cell) castLocked(origin, dir r3.Vector, objects []Object) (float64, int, bool) {
+	best, hit := math.Inf(1), -1
+	if dir.Z != 0 {
+		t := (c.cfg.TableZ - origin.Z) / dir.Z
+		hit := origin.Add(dir.Mul(t))
+		if t > 0 && hit.X >= c.cfg.TableMin.X && hit.X <= c.cfg.TableMax.X &&
+			hit.Y >= c.cfg.TableMin.Y && hit.Y <= c.cfg.TableMax.Y {
+			best = t
+		}
+	}
+	for i, obj := range objects {
+		if t, ok := intersect(obj, origin, dir); ok && t < best {
+			best, hit = t, i
+		}
+	}
+	return best, hit, !math.IsInf(best, 1)
+}
+
+// toObjectFrame expresses a world offset from an object's base center in the object's
+// frame, which is yawed about Z.
+func toObjectFrame(obj Object, v r3.Vector) r3.Vector {
+	yaw := obj.YawDeg * math.Pi / 180
+	cos, sin := math.Cos(yaw), math.Sin(yaw)
+	return r3.Vector{X: cos*v.X + sin*v.Y, Y: -sin*v.X + cos*v.Y, Z: v.Z}
+}
+
+// intersect returns the distance along a ray to an object's surface.
+func intersect(obj Object, origin, dir r3.Vector) (float64, bool) {
+	// Work in the object's frame: base center at the origin, yawed about Z.
+	o := toObjectFrame(obj, origin.Sub(obj.Position))
+	d := toObjectFrame(obj, dir)
+
+	switch obj.Shape {
+	case Box:
+		return intersectBox(o, d, obj.Size)
+	case Cylinder:
+		return intersectCylinder(o, d, obj.Size.X/2, obj.Size.Z)
+	default:
+		return 0, false
+	}
+}
+
+// intersectBox is the slab test against a box spanning ±size/2 in X and Y and [0, size.Z] in Z.
+func intersectBox(o, d, size r3.Vector) (float64, bool) {
+	lo := [3]float64{-size.X / 2, -size.Y / 2, 0}
+	hi := [3]float64{size.X / 2, size.Y / 2, size.Z}
+	oa := [3]float64{o.X, o.Y, o.Z}
+	da := [3]float64{d.X, d.Y, d.Z}
+	tMin, tMax := math.Inf(-1), math.Inf(1)
+	for i := 0; i < 3; i++ {
+		if da[i] == 0 {
+			if oa[i] < lo[i] || oa[i] > hi[i] {
+				return 0, false
+			}
+			continue
+		}
+		t1, t2 := (lo[i]-oa[i])/da[i], (hi[i]-oa[i])/da[i]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin, tMax = math.Max(tMin, t1), math.Min(tMax, t2)
+	}
+	if tMin > tMax || tMin <= 0 {
+		return 0, false
+	}
+	return tMin, true
+}
+
+// intersectCylinder hits a vertical cylinder of the given radius spanning [0, height] in Z.
+func intersectCylinder(o, d r3.Vector, radius, height float64) (float64, bool) {
+	best := math.Inf(1)
+	// Side wall
+	a := d.X*d.X + d.Y*d.Y
+	if a > 0 {
+		b := 2 * (o.X*d.X + o.Y*d.Y)
+		cc := o.X*o.X + o.Y*o.Y - radius*radius
+		if disc := b*b - 4*a*cc; disc >= 0 {
+			t := (-b - math.Sqrt(disc)) / (2 * a)
+			if z := o.Z + t*d.Z; t > 0 && z >= 0 && z <= height {
+				best = t
+			}
+		}
+	}
+	// Top and bottom caps
+	if d.Z != 0 {
+		for _, z := range []float64{0, height} {
+			t := (z - o.Z) / d.Z
+			x, y := o.X+t*d.X, o.Y+t*d.Y
+			if t > 0 && x*x+y*y <= radius*radius && t < best {
+				best = t
+			}
+		}
+	}
+	return best, !math.IsInf(best, 1)
+}
@@ -0,0 +1,1233 @@
+package handeyetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	pc "go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+
+	"handeyetest/sim"
+)
+
+// newSimService builds the service against a simulated cell, with the config validated
+// as the module would.
+func newSimService(t *testing.T, cell *sim.Cell, cfg Config) *handEyeTest {
+	t.Helper()
+	cfg.Arm, cfg.Camera = "arm", "camera"
+	// A tool frame alone stands for a probe-only arm, with no gripper.
+	if cfg.ToolFrame == "" {
+		cfg.Gripper = "gripper"
+	}
+	if _, _, err := cfg.Validate("test"); err != nil {
+		t.Fatal(err)
+	}
+	return newUnvalidatedSimService(t, cell, cfg)
+}
+
+// newUnvalidatedSimService builds the service from a config that was never validated, as
+// the CLI does.
+func newUnvalidatedSimService(t *testing.T, cell *sim.Cell, cfg Config) *handEyeTest {
+	t.Helper()
+	cfg.Arm, cfg.Camera = "arm", "camera"
+	if cfg.ToolFrame == "" {
+		cfg.Gripper = "gripper"
+	}
+	svc, err := NewHandEyeTest(context.Background(), cell.Dependencies(), generic.Named("test"), &cfg, logging.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { svc.Close(context.Background()) })
+	return svc.(*handEyeTest)
+}
+
+// runJob issues a command and, if it starts a job, waits for the job's result.
+func runJob(t *testing.T, svc *handEyeTest, cmd map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	resp, err := svc.DoCommand(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("%v: %v", cmd["command"], err)
+	}
+	jobID, ok := resp["job_id"].(string)
+	if !ok {
+		return resp
+	}
+	j := waitJob(t, svc, jobID)
+	if j["state"] != jobSucceeded {
+		t.Fatalf("job %s %s: %v", jobID, j["state"], j["error"])
+	}
+	return j["result"].(map[string]interface{})
+}
+
+// waitJob waits for a job to finish and returns its status.
+func waitJob(t *testing.T, svc *handEyeTest, jobID string) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		status, err := svc.DoCommand(context.Background(), map[string]interface{}{"command": "status", "job_id": jobID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j := status["job"].(map[string]interface{}); j["state"] != jobRunning {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", jobID)
+	return nil
+}
+
+// vectorFromMap reads a result block with the given x/y/z keys.
+func vectorFromMap(t *testing.T, m interface{}, x, y, z string) r3.Vector {
+	t.Helper()
+	mm, ok := m.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected a map, got %T", m)
+	}
+	return r3.Vector{X: mm[x].(float64), Y: mm[y].(float64), Z: mm[z].(float64)}
+}
+
+func assertNear(t *testing.T, what string, got, want r3.Vector, tolMm float64) {
+	t.Helper()
+	if d := got.Sub(want).Norm(); d > tolMm {
+		t.Errorf("%s = (%.2f, %.2f, %.2f), want (%.2f, %.2f, %.2f) within %.1fmm (off by %.2fmm)",
+			what, got.X, got.Y, got.Z, want.X, want.Y, want.Z, tolMm, d)
+	}
+}
+
+func TestSimJobs(t *testing.T) {
+	svc := newSimService(t, sim.NewCell(sim.DefaultConfig()), Config{})
+	var armStops, gripperStops int
+	svc.arm.(*inject.Arm).StopFunc = func(context.Context, map[string]interface{}) error {
+		armStops++
+		return nil
+	}
+	svc.gripper.(*inject.Gripper).StopFunc = func(context.Context, map[string]interface{}) error {
+		gripperStops++
+		return nil
+	}
+
+	// A job that reports a step, then holds the arm until it is cancelled.
+	stepped := make(chan struct{})
+	resp, err := svc.startJob("hold", func(ctx context.Context) (map[string]interface{}, error) {
+		svc.reportProgress(ctx, "holding")
+		close(stepped)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobID := resp["job_id"].(string)
+	<-stepped
+	status, err := svc.DoCommand(context.Background(), map[string]interface{}{"command": "status", "job_id": jobID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step := status["job"].(map[string]interface{})["current_step"]; step != "holding" {
+		t.Errorf("current step %v, want holding", step)
+	}
+
+	resp, err = svc.DoCommand(context.Background(), map[string]interface{}{"command": "cancel"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp["job_id"] != jobID {
+		t.Errorf("cancelled %v, want %s", resp["job_id"], jobID)
+	}
+	if j := waitJob(t, svc, jobID); j["state"] != jobCancelled {
+		t.Errorf("job %s, want %s", j["state"], jobCancelled)
+	}
+	if armStops != 1 || gripperStops != 1 {
+		t.Errorf("cancel stopped the arm %d times and the gripper %d times, want once each", armStops, gripperStops)
+	}
+	if _, err := svc.DoCommand(context.Background(), map[string]interface{}{"command": "cancel"}); err == nil {
+		t.Error("cancel with no running job succeeded")
+	}
+
+	// Only the most recent finished jobs are kept.
+	for i := 0; i < maxFinishedJobs+1; i++ {
+		resp, err := svc.startJob("noop", func(context.Context) (map[string]interface{}, error) { return nil, nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitJob(t, svc, resp["job_id"].(string))
+	}
+	svc.mu.Lock()
+	kept := len(svc.jobs)
+	svc.mu.Unlock()
+	if kept != maxFinishedJobs+1 {
+		t.Errorf("kept %d jobs, want %d", kept, maxFinishedJobs+1)
+	}
+	if _, err := svc.DoCommand(context.Background(), map[string]interface{}{"command": "status", "job_id": jobID}); err == nil {
+		t.Errorf("job %s was not pruned", jobID)
+	}
+}
+
+func TestSimBusy(t *testing.T) {
+	ctx := context.Background()
+	svc := newSimService(t, sim.NewCell(sim.DefaultConfig()), Config{})
+	before := runJob(t, svc, map[string]interface{}{"command": "detect"})
+
+	// A job that holds the arm until it is cancelled.
+	started := make(chan struct{})
+	resp, err := svc.startJob("hold", func(ctx context.Context) (map[string]interface{}, error) {
+		svc.mu.Lock()
+		svc.currentStatus = "holding"
+		svc.mu.Unlock()
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobID := resp["job_id"].(string)
+	<-started
+	t.Cleanup(func() { svc.DoCommand(ctx, map[string]interface{}{"command": "cancel", "job_id": jobID}) })
+
+	var busy *busyError
+	if _, err := svc.DoCommand(ctx, map[string]interface{}{"command": "pick"}); !errors.As(err, &busy) || busy.op.JobID != jobID {
+		t.Errorf("pick while %s runs: %v, want a busy error naming it", jobID, err)
+	}
+
+	// Detect still reports objects, but leaves the job's status and the kept detection alone.
+	during := runJob(t, svc, map[string]interface{}{"command": "detect"})
+	if during["count"] != before["count"] {
+		t.Errorf("detect during a job found %v objects, want %v", during["count"], before["count"])
+	}
+	if _, ok := during["detection_id"]; ok {
+		t.Error("detect during a job was kept for pick_detected")
+	}
+	status := runJob(t, svc, map[string]interface{}{"command": "status"})
+	if status["status"] != "holding" {
+		t.Errorf("status %v after detect during a job, want holding", status["status"])
+	}
+	svc.mu.Lock()
+	seq := svc.lastDetection.Seq
+	svc.mu.Unlock()
+	if seq != before["detection_id"] {
+		t.Errorf("last detection is %d, want %v", seq, before["detection_id"])
+	}
+
+	svc.cfg.BlockDetectWhileBusy = true
+	if _, err := svc.DoCommand(ctx, map[string]interface{}{"command": "detect"}); !errors.As(err, &busy) {
+		t.Errorf("detect with block_detect_while_busy: %v, want a busy error", err)
+	}
+}
+
+func TestSimJobDuringDetect(t *testing.T) {
+	ctx := context.Background()
+	svc := newSimService(t, sim.NewCell(sim.DefaultConfig()), Config{})
+
+	// Hold the detect inside its capture until a job has tried to start.
+	cam := svc.camera.(*inject.Camera)
+	capture := cam.NextPointCloudFunc
+	capturing, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	cam.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pc.PointCloud, error) {
+		once.Do(func() {
+			close(capturing)
+			<-release
+		})
+		return capture(ctx, extra)
+	}
+	detected := make(chan error, 1)
+	go func() {
+		_, err := svc.DoCommand(ctx, map[string]interface{}{"command": "detect"})
+		detected <- err
+	}()
+	<-capturing
+
+	var busy *busyError
+	if _, err := svc.DoCommand(ctx, map[string]interface{}{"command": "pick"}); !errors.As(err, &busy) || busy.op.Name != "detect" {
+		t.Errorf("pick during detect: %v, want a busy error naming detect", err)
+	}
+	close(release)
+	if err := <-detected; err != nil {
+		t.Fatal(err)
+	}
+
+	status := runJob(t, svc, map[string]interface{}{"command": "status"})
+	if status["status"] != "idle" || status["operation"] != nil {
+		t.Errorf("after detect: status %v, operation %v; want idle and none", status["status"], status["operation"])
+	}
+}
+
+func TestSimDetect(t *testing.T) {
+	cfg := sim.DefaultConfig()
+	cfg.Objects = append(cfg.Objects, sim.Object{
+		Shape: sim.Cylinder, Position: r3.Vector{X: 520, Y: 120}, Size: r3.Vector{X: 50, Z: 60},
+	})
+	cell := sim.NewCell(cfg)
+	svc := newSimService(t, cell, Config{})
+
+	resp := runJob(t, svc, map[string]interface{}{"command": "detect"})
+	if resp["count"] != 2 {
+		t.Fatalf("detected %v objects, want 2", resp["count"])
+	}
+
+	// The camera looks straight down from 500mm, so in its frame the table is z = 500 and
+	// up toward the objects is -Z.
+	plane := resp["ground_plane"].(map[string]interface{})
+	assertNear(t, "ground plane normal", vectorFromMap(t, plane["normal"], "x", "y", "z"), r3.Vector{Z: -1}, 0.01)
+	if offset := plane["offset_mm"].(float64); math.Abs(offset-500) > 1 {
+		t.Errorf("ground plane offset = %.1fmm, want 500mm", offset)
+	}
+
+	camPose, err := cell.TrueFramePose("camera")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := resp["objects"].([]interface{})
+	for _, want := range cell.Objects() {
+		// Seen from above, the cluster is the top face; its center is the top center.
+		found := false
+		for _, o := range objects {
+			center := vectorFromMap(t, o, "center_x_mm", "center_y_mm", "center_z_mm")
+			world := spatialmath.Compose(camPose, spatialmath.NewPoseFromPoint(center)).Point()
+			if math.Hypot(world.X-want.Position.X, world.Y-want.Position.Y) < 10 {
+				found = true
+				assertNear(t, "detected center", world, want.Top(), 3)
+				height := o.(map[string]interface{})["height_above_plane_mm"].(float64)
+				if math.Abs(height-want.Size.Z) > 3 {
+					t.Errorf("height above plane = %.1f, want %.1f", height, want.Size.Z)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("object at (%.0f, %.0f) not detected", want.Position.X, want.Position.Y)
+		}
+	}
+}
+
+func TestSimFiducial(t *testing.T) {
+	// A marker on a cube yawed 30 degrees; the cylinder has none and must be ignored.
+	yaw := 30 * math.Pi / 180
+	cfg := sim.DefaultConfig()
+	cfg.ImageWidth, cfg.ImageHeight = 640, 480
+	cfg.Objects[0].YawDeg = 30
+	cfg.Objects[0].Marker = &sim.Marker{ID: 42, SizeMm: 30}
+	cfg.Objects = append(cfg.Objects, sim.Object{
+		Shape: sim.Cylinder, Position: r3.Vector{X: 520, Y: 120}, Size: r3.Vector{X: 50, Z: 60},
+	})
+	cell := sim.NewCell(cfg)
+	svc := newSimService(t, cell, Config{
+		Detector:           detectorFiducial,
+		Fiducial:           FiducialConfig{MarkerSizeMm: 30},
+		GraspDepthOffsetMm: -15,
+		GraspOrientation:   graspAlignToObject,
+	})
+
+	resp := runJob(t, svc, map[string]interface{}{"command": "detect"})
+	if resp["count"] != 1 {
+		t.Fatalf("detected %v objects, want 1", resp["count"])
+	}
+	obj := resp["objects"].([]interface{})[0].(map[string]interface{})
+	if obj["id"] != 42 {
+		t.Errorf("marker id = %v, want 42", obj["id"])
+	}
+
+	// Unlike a cluster centroid, the marker's center is exactly the top center.
+	camPose, err := cell.TrueFramePose("camera")
+	if err != nil {
+		t.Fatal(err)
+	}
+	center := vectorFromMap(t, obj, "center_x_mm", "center_y_mm", "center_z_mm")
+	world := spatialmath.Compose(camPose, spatialmath.NewPoseFromPoint(center)).Point()
+	assertNear(t, "marker center", world, cell.Objects()[0].Top(), 0.5)
+
+	o := obj["orientation"].(map[string]interface{})
+	markerOrientation := &spatialmath.OrientationVectorDegrees{
+		OX: o["o_x"].(float64), OY: o["o_y"].(float64), OZ: o["o_z"].(float64), Theta: o["theta"].(float64),
+	}
+	markerWorld := spatialmath.Compose(camPose, spatialmath.NewPoseFromOrientation(markerOrientation)).Orientation()
+	markerX := rotate(markerWorld, r3.Vector{X: 1})
+	assertNear(t, "marker X axis", markerX, r3.Vector{X: math.Cos(yaw), Y: math.Sin(yaw)}, 0.02)
+	assertNear(t, "marker Z axis", rotate(markerWorld, r3.Vector{Z: 1}), r3.Vector{Z: 1}, 0.02)
+
+	result := runJob(t, svc, map[string]interface{}{"command": "pick", "place": true})
+	if result["success"] != true {
+		t.Fatalf("pick failed: %v", result["verdict"])
+	}
+	worldOffset := vectorFromMap(t, result["world_frame_offset_mm"], "x", "y", "z")
+	assertNear(t, "world frame offset", worldOffset, r3.Vector{Z: -15}, 1)
+
+	// align_to_object closes the jaws (gripper X) along one of the marker's edges.
+	gripperPose, err := cell.TrueFramePose("gripper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jaws := rotate(gripperPose.Orientation(), r3.Vector{X: 1})
+	if dot := math.Abs(jaws.Dot(markerX)); dot < 0.999 && dot > 0.001 {
+		t.Errorf("jaws at %.1f degrees to the marker's edges", math.Acos(dot)*180/math.Pi)
+	}
+}
+
+func TestMarkerReferenceImage(t *testing.T) {
+	// Original-dictionary marker 620 (rows 01001, 10111, 01001, 01110, 10000, 1 = white),
+	// drawn in perspective independently of the simulated camera's renderer.
+	f, err := os.Open("testdata/aruco_original_620.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	markers := findMarkers(toGray(img))
+	if len(markers) != 1 || markers[0].ID != 620 {
+		t.Fatalf("found markers %+v, want only 620", markers)
+	}
+	want := quadCorners{{X: 92.3, Y: 61.7}, {X: 231.6, Y: 83.2}, {X: 214.4, Y: 206.9}, {X: 78.5, Y: 189.1}}
+	for i, c := range markers[0].Corners {
+		if d := c.Sub(want[i]).Norm(); d > 1 {
+			t.Errorf("corner %d at %v, want %v", i, c, want[i])
+		}
+	}
+}
+
+func TestToGraySubImage(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 8, 6))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i)
+	}
+	// Both keep the source's 8-pixel stride; one starts at the origin.
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 5, 4), image.Rect(2, 1, 5, 4)} {
+		gray := toGray(src.SubImage(r))
+		if gray.Rect != image.Rect(0, 0, r.Dx(), r.Dy()) {
+			t.Fatalf("%v: bounds %v, want the same size at the origin", r, gray.Rect)
+		}
+		for y := 0; y < r.Dy(); y++ {
+			for x := 0; x < r.Dx(); x++ {
+				if got, want := gray.GrayAt(x, y), src.GrayAt(r.Min.X+x, r.Min.Y+y); got != want {
+					t.Errorf("%v: pixel (%d, %d) = %v, want %v", r, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSimCheckerboard(t *testing.T) {
+	// A 7x6 board of 20mm squares, yawed so its rows are not along the image axes.
+	yaw := 20 * math.Pi / 180
+	newCell := func(calibrationError spatialmath.Pose) *sim.Cell {
+		cfg := sim.DefaultConfig()
+		cfg.ImageWidth, cfg.ImageHeight = 640, 480
+		cfg.CalibrationError = calibrationError
+		cfg.Objects = []sim.Object{{
+			Shape: sim.Box, Position: r3.Vector{X: 450}, Size: r3.Vector{X: 200, Y: 180, Z: 3}, YawDeg: 20,
+			Checkerboard: &sim.Checkerboard{InnerCols: 7, InnerRows: 6, SquareMm: 20},
+		}}
+		return sim.NewCell(cfg)
+	}
+	svcConfig := Config{
+		Detector:     detectorCheckerboard,
+		Checkerboard: CheckerboardConfig{InnerCorners: []int{7, 6}, SquareSizeMm: 20},
+	}
+	sweep := map[string]interface{}{
+		"command": "sweep", "target": []interface{}{450.0, 0.0, 3.0}, "radius_mm": 150.0, "height_mm": 350.0, "count": 6.0,
+	}
+
+	cell := newCell(spatialmath.NewZeroPose())
+	svc := newSimService(t, cell, svcConfig)
+	resp := runJob(t, svc, map[string]interface{}{"command": "detect"})
+	if resp["count"] != 1 {
+		t.Fatalf("detected %v objects, want 1", resp["count"])
+	}
+	obj := resp["objects"].([]interface{})[0].(map[string]interface{})
+	if reproj := obj["reprojection_error_px"].(float64); reproj > 0.5 {
+		t.Errorf("reprojection error %.2fpx, want < 0.5", reproj)
+	}
+	camPose, err := cell.TrueFramePose("camera")
+	if err != nil {
+		t.Fatal(err)
+	}
+	center := vectorFromMap(t, obj, "center_x_mm", "center_y_mm", "center_z_mm")
+	world := spatialmath.Compose(camPose, spatialmath.NewPoseFromPoint(center)).Point()
+	assertNear(t, "board center", world, cell.Objects()[0].Top(), 0.5)
+
+	o := obj["orientation"].(map[string]interface{})
+	boardOrientation := &spatialmath.OrientationVectorDegrees{
+		OX: o["o_x"].(float64), OY: o["o_y"].(float64), OZ: o["o_z"].(float64), Theta: o["theta"].(float64),
+	}
+	boardWorld := spatialmath.Compose(camPose, spatialmath.NewPoseFromOrientation(boardOrientation)).Orientation()
+	assertNear(t, "board X axis", rotate(boardWorld, r3.Vector{X: 1}), r3.Vector{X: math.Cos(yaw), Y: math.Sin(yaw)}, 0.005)
+	assertNear(t, "board Z axis", rotate(boardWorld, r3.Vector{Z: 1}), r3.Vector{Z: 1}, 0.005)
+
+	// The board's orientation holds still across the sweep, until the mount is off by a degree.
+	// The sim's edges are not antialiased, which leaves a few tenths of a degree of noise.
+	result := runJob(t, svc, sweep)
+	if dev := result["orientation_deviation_deg"].(map[string]interface{})["max"].(float64); dev > 0.4 {
+		t.Errorf("calibrated sweep: orientation deviation %.3f degrees, want < 0.4", dev)
+	}
+	tilted := newSimService(t, newCell(spatialmath.NewPoseFromOrientation(&spatialmath.OrientationVectorDegrees{OX: 0.0175, OZ: 1})), svcConfig)
+	result = runJob(t, tilted, sweep)
+	if dev := result["orientation_deviation_deg"].(map[string]interface{})["max"].(float64); dev < 0.8 {
+		t.Errorf("1 degree mount error: orientation deviation %.3f degrees, want > 0.8", dev)
+	}
+}
+
+func TestSimSweepOverrides(t *testing.T) {
+	cell := sim.NewCell(sim.DefaultConfig())
+	svc := newSimService(t, cell, Config{
+		Sweep: SweepConfig{Target: []float64{450, 0, 40}, RadiusMm: 100, HeightMm: 300, Count: 3},
+	})
+	want := SweepConfig{Target: []float64{450, 0, 40}, RadiusMm: 100, HeightMm: 300, Count: 3}
+
+	// Overrides apply to their own sweep only; the configured sweep is unchanged for the next.
+	for _, target := range [][]interface{}{{460.0, 0.0, 40.0}, {440.0, 10.0, 40.0}} {
+		runJob(t, svc, map[string]interface{}{"command": "sweep", "target": target})
+		if !reflect.DeepEqual(svc.cfg.Sweep, want) {
+			t.Errorf("after sweep with target %v, configured sweep is %+v, want %+v", target, svc.cfg.Sweep, want)
+		}
+	}
+
+	// Every pose is reported once it is done, the last one included.
+	resp, err := svc.DoCommand(context.Background(), map[string]interface{}{"command": "sweep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := waitJob(t, svc, resp["job_id"].(string))["steps_completed"]
+	if want := []string{"pose_1", "pose_2", "pose_3"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps = %v, want %v", steps, want)
+	}
+
+	// With neither a radius nor a height, every pose would sit on the target.
+	if _, err := svc.DoCommand(context.Background(), map[string]interface{}{
+		"command": "sweep", "radius_mm": 0.0, "height_mm": 0.0,
+	}); err == nil {
+		t.Error("sweep with radius_mm and height_mm 0 was accepted")
+	}
+	degenerate := Config{Arm: "arm", Camera: "camera", Gripper: "gripper", Sweep: SweepConfig{Target: []float64{450, 0, 40}, Count: 3}}
+	if _, _, err := degenerate.Validate("test"); err == nil {
+		t.Error("config with a sweep of radius_mm and height_mm 0 was accepted")
+	}
+}
+
+func TestPresentOverrides(t *testing.T) {
+	defaults := PresentConfig{
+		Poses: []PoseConfig{{X: 400, Z: 300}}, Center: []float64{450, 0, 300}, TargetOffsetMm: []float64{0, 0, 20},
+	}
+	want := PresentConfig{
+		Poses: []PoseConfig{{X: 400, Z: 300}}, Center: []float64{450, 0, 300}, TargetOffsetMm: []float64{0, 0, 20},
+	}
+	override := map[string]interface{}{
+		"poses":            []interface{}{map[string]interface{}{"x": 9.0, "y": 9.0, "z": 9.0}},
+		"center":           []interface{}{9.0, 9.0, 9.0},
+		"target_offset_mm": []interface{}{9.0, 9.0, 9.0},
+	}
+	got, err := parsePresentConfig(override, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Center[0] != 9 || got.TargetOffsetMm[0] != 9 || got.Poses[0].X != 9 {
+		t.Errorf("overrides not applied: %+v", got)
+	}
+	if !reflect.DeepEqual(defaults, want) {
+		t.Errorf("overrides changed the configured presentation to %+v", defaults)
+	}
+}
+
+func TestSimPickReportsCalibrationError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     spatialmath.Pose
+		holding bool
+	}{
+		{"calibrated", spatialmath.NewZeroPose(), true},
+		{"translation", spatialmath.NewPoseFromPoint(r3.Vector{X: 6, Y: -4}), true},
+		{"rotation", spatialmath.NewPoseFromOrientation(&spatialmath.OrientationVectorDegrees{OX: 0.015, OZ: 1}), true},
+		{"beyond grasp tolerance", spatialmath.NewPoseFromPoint(r3.Vector{X: 25}), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := sim.DefaultConfig()
+			cfg.CalibrationError = tc.err
+			cell := sim.NewCell(cfg)
+			svc := newSimService(t, cell, Config{GraspDepthOffsetMm: -15})
+
+			result := runJob(t, svc, map[string]interface{}{"command": "pick", "place": true})
+			if result["success"] != tc.holding {
+				t.Fatalf("success = %v, want %v", result["success"], tc.holding)
+			}
+			if !tc.holding {
+				if _, placed := result["place"]; placed {
+					t.Error("place ran without holding the object")
+				}
+				return
+			}
+
+			// The gripper goes where the tester believes the object is, so in world frame
+			// it lines up with the detection apart from the grasp depth (and a little
+			// sideways descent when a rotation error tilts the approach).
+			worldOffset := vectorFromMap(t, result["world_frame_offset_mm"], "x", "y", "z")
+			assertNear(t, "world frame offset", worldOffset, r3.Vector{Z: -15}, 2)
+
+			// The gripper drags the object onto its axis, so after placing, the object sits
+			// where the tester believed it was, give or take the sideways descent.
+			// Re-detecting it then shows the calibration error directly.
+			place := result["place"].(map[string]interface{})
+			if place["redetected"] != true {
+				t.Fatalf("placed object not re-detected: %v", place)
+			}
+			want := cell.ApparentError(cell.Objects()[0].Top()).Add(r3.Vector{X: worldOffset.X, Y: worldOffset.Y})
+			got := vectorFromMap(t, place["place_offset_mm"], "x", "y", "z")
+			assertNear(t, "place offset", got, want, 1.5)
+		})
+	}
+}
+
+func TestSimPickObjectWorldPosition(t *testing.T) {
+	cell := sim.NewCell(sim.DefaultConfig())
+	svc := newSimService(t, cell, Config{GraspDepthOffsetMm: -15})
+
+	// The detection goes to world with the camera pose it was taken from. Once the arm has
+	// moved, the camera is elsewhere and the same transform would put the object there too.
+	top := cell.Objects()[0].Top()
+	result := runJob(t, svc, map[string]interface{}{"command": "pick"})
+	got := vectorFromMap(t, result["object_position_world_frame"], "x_mm", "y_mm", "z_mm")
+	assertNear(t, "object world position", got, top, 1)
+}
+
+func TestSimGraspDepthOffset(t *testing.T) {
+	for _, offset := range []float64{-5, -20} {
+		cell := sim.NewCell(sim.DefaultConfig())
+		svc := newSimService(t, cell, Config{GraspDepthOffsetMm: offset})
+		top := cell.Objects()[0].Top()
+
+		// The gripper stops the offset above the detected point, so a negative offset grasps
+		// below it, in the plan and the pick.
+		plan := runJob(t, svc, map[string]interface{}{"command": "pick", "dry_run": true})
+		grasp := plan["moves"].([]interface{})[1].(map[string]interface{})
+		got := vectorFromMap(t, grasp["pose_world"], "x_mm", "y_mm", "z_mm")
+		assertNear(t, "planned grasp", got, top.Add(r3.Vector{Z: offset}), 1)
+
+		result := runJob(t, svc, map[string]interface{}{"command": "pick"})
+		got = vectorFromMap(t, result["world_frame_offset_mm"], "x", "y", "z")
+		assertNear(t, "grasp world offset", got, r3.Vector{Z: offset}, 1)
+	}
+}
+
+func TestSimPickTiltedCamera(t *testing.T) {
+	// A wrist camera tilted 15 degrees off the gripper axis. Approaching along its Z axis
+	// would come down 25mm beside the cube; the table normal leads straight onto it.
+	tilt := 15 * math.Pi / 180
+	cfg := sim.DefaultConfig()
+	cfg.CameraMount = spatialmath.NewPoseFromOrientation(&spatialmath.OrientationVectorDegrees{OX: math.Sin(tilt), OZ: math.Cos(tilt)})
+	cell := sim.NewCell(cfg)
+	svc := newSimService(t, cell, Config{GraspDepthOffsetMm: -15})
+
+	result := runJob(t, svc, map[string]interface{}{"command": "pick", "place": true})
+	if result["success"] != true {
+		t.Fatalf("pick failed: %v", result["verdict"])
+	}
+	worldOffset := vectorFromMap(t, result["world_frame_offset_mm"], "x", "y", "z")
+	assertNear(t, "world frame offset", worldOffset, r3.Vector{Z: -15}, 1)
+
+	gripperPose, err := cell.TrueFramePose("gripper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ov := gripperPose.Orientation().OrientationVectorDegrees()
+	assertNear(t, "gripper direction", r3.Vector{X: ov.OX, Y: ov.OY, Z: ov.OZ}, r3.Vector{Z: -1}, 0.01)
+}
+
+func TestSimPickVerdict(t *testing.T) {
+	thresholds := ThresholdsConfig{MaxWorldOffsetMm: 2, MaxWorldOffsetZMm: 1, MaxRedetectDriftMm: 3}
+	tests := []struct {
+		name    string
+		err     spatialmath.Pose
+		pass    bool
+		reasons []string
+	}{
+		{"calibrated", spatialmath.NewZeroPose(), true, nil},
+		{"translation", spatialmath.NewPoseFromPoint(r3.Vector{X: 6, Y: -4}), false, []string{"re-detect drift 7.2mm > 3mm limit"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := sim.DefaultConfig()
+			cfg.CalibrationError = tc.err
+			cell := sim.NewCell(cfg)
+			svc := newSimService(t, cell, Config{GraspDepthOffsetMm: -15, Thresholds: thresholds})
+
+			result := runJob(t, svc, map[string]interface{}{"command": "pick", "place": true})
+			v := result["verdict"].(map[string]interface{})
+			if v["pass"] != tc.pass || result["success"] != tc.pass {
+				t.Fatalf("verdict = %v, success = %v, want pass %v", v, result["success"], tc.pass)
+			}
+			reasons := v["reasons"].([]string)
+			if len(reasons) != len(tc.reasons) {
+				t.Fatalf("reasons = %q, want %q", reasons, tc.reasons)
+			}
+			for i := range reasons {
+				if reasons[i] != tc.reasons[i] {
+					t.Errorf("reason %d = %q, want %q", i, reasons[i], tc.reasons[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSimHealthSensor(t *testing.T) {
+	cell := sim.NewCell(sim.DefaultConfig())
+	svc := newSimService(t, cell, Config{GraspDepthOffsetMm: -15, HistoryFile: filepath.Join(t.TempDir(), "history.jsonl")})
+	healthCfg := HealthConfig{Tester: "test"}
+	if _, _, err := healthCfg.Validate("health"); err != nil {
+		t.Fatal(err)
+	}
+	health, err := NewCalibrationHealth(resource.Dependencies{generic.Named("test"): svc},
+		sensor.Named("health"), &healthCfg, logging.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A sensor built without Validate, as in code, still gets the default window.
+	unvalidated, err := NewCalibrationHealth(resource.Dependencies{generic.Named("test"): svc},
+		sensor.Named("unvalidated"), &HealthConfig{Tester: "test"}, logging.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := unvalidated.(*calibrationHealth).window; w != 20 {
+		t.Errorf("window without Validate = %d, want 20", w)
+	}
+
+	readings, err := health.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readings["picks"] != 0 {
+		t.Errorf("readings before any pick = %v", readings)
+	}
+
+	runJob(t, svc, map[string]interface{}{"command": "pick", "place": true})
+	readings, err = health.Readings(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readings["picks"] != 1 || readings["success_rate"] != 1.0 || readings["success"] != true {
+		t.Errorf("readings after one pick = %v", readings)
+	}
+	if v, _ := readings["world_frame_offset_mm"].(float64); math.Abs(v-15) > 0.5 {
+		t.Errorf("world_frame_offset_mm = %v, want the 15mm grasp depth", readings["world_frame_offset_mm"])
+	}
+	if _, ok := readings["timestamp"].(string); !ok {
+		t.Errorf("missing timestamp: %v", readings)
+	}
+
+	// Polls reuse the parsed history until the file changes: an unreadable file of the same
+	// size and modification time is not re-read.
+	info, err := os.Stat(svc.cfg.HistoryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(svc.cfg.HistoryFile, bytes.Repeat([]byte("x"), int(info.Size())), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(svc.cfg.HistoryFile, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if readings, err = health.Readings(context.Background(), nil); err != nil || readings["picks"] != 1 {
+		t.Errorf("cached readings = %v, %v", readings, err)
+	}
+	if err := os.Remove(svc.cfg.HistoryFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// Appending a pick reloads the history.
+	runJob(t, svc, map[string]interface{}{"command": "pick", "place": true})
+	if readings, err = health.Readings(context.Background(), nil); err != nil || readings["picks"] != 1 {
+		t.Errorf("readings after the file was replaced by one pick = %v, %v", readings, err)
+	}
+}
+
+func TestSimStaticCamera(t *testing.T) {
+	// present picks the cube with a static camera, then shows it to the camera from five
+	// poses and returns the mean and std-dev of the offset between where it is seen and
+	// where the arm holds it.
+	present := func(t *testing.T, cell *sim.Cell) (r3.Vector, float64) {
+		t.Helper()
+		svc := newSimService(t, cell, Config{CameraMount: cameraEyeToHand, GraspDepthOffsetMm: -15})
+
+		pick := runJob(t, svc, map[string]interface{}{"command": "pick"})
+		if pick["success"] != true {
+			t.Fatalf("static camera pick failed: %v", pick["verdict"])
+		}
+		for _, step := range pick["steps_completed"].([]string) {
+			if step == "re_detect" {
+				t.Error("re-detected from the approach position with a static camera")
+			}
+		}
+		worldOffset := vectorFromMap(t, pick["world_frame_offset_mm"], "x", "y", "z")
+		assertNear(t, "world frame offset", worldOffset, r3.Vector{Z: -15}, 1)
+
+		// Gripper Z points down, so the cube's top face is 15mm along -Z.
+		resp, err := svc.DoCommand(context.Background(), map[string]interface{}{
+			"command": "present", "center": []interface{}{450.0, 0.0, 200.0}, "radius_mm": 50.0, "count": 4.0,
+			"target_offset_mm": []interface{}{0.0, 0.0, -15.0},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		j := waitJob(t, svc, resp["job_id"].(string))
+		if j["state"] != jobSucceeded {
+			t.Fatalf("present %s: %v", j["state"], j["error"])
+		}
+		if want := []string{"pose_1", "pose_2", "pose_3", "pose_4", "pose_5"}; !reflect.DeepEqual(j["steps_completed"], want) {
+			t.Errorf("present steps = %v, want %v", j["steps_completed"], want)
+		}
+		result := j["result"].(map[string]interface{})
+		if result["observed"] != 5 {
+			t.Fatalf("observed %v of 5 poses: %v", result["observed"], result["observations"])
+		}
+		offset := result["world_frame_offset_mm"].(map[string]interface{})
+		return vectorFromMap(t, offset["mean"], "x_mm", "y_mm", "z_mm"),
+			offset["std_dev_mm"].(map[string]interface{})["total"].(float64)
+	}
+
+	// The tilted camera sees a side of the cube as well as its top, which pulls the
+	// detected center off the top face by an amount that depends on the view. Comparing
+	// against a calibrated camera leaves only the calibration error. The error is along
+	// camera Y, which is horizontal: a vertical error would also change how deep the cube
+	// was grasped, and so where it sits in the gripper.
+	baseline, baselineSpread := present(t, sim.NewCell(sim.StaticCameraConfig()))
+	cfg := sim.StaticCameraConfig()
+	cfg.CalibrationError = spatialmath.NewPoseFromPoint(r3.Vector{Y: 5})
+	cell := sim.NewCell(cfg)
+	got, spread := present(t, cell)
+	assertNear(t, "present offset from calibration error", got.Sub(baseline), cell.ApparentError(r3.Vector{X: 450, Z: 200}), 0.5)
+	if math.Abs(spread-baselineSpread) > 0.5 {
+		t.Errorf("offset std-dev = %.2fmm, want %.2fmm as calibrated: a translation error should not spread the offsets",
+			spread, baselineSpread)
+	}
+}
+
+func TestSimDryRunDoesNotMove(t *testing.T) {
+	cell := sim.NewCell(sim.DefaultConfig())
+	svc := newSimService(t, cell, Config{})
+	before, err := cell.TrueFramePose("gripper")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := runJob(t, svc, map[string]interface{}{"command": "pick", "dry_run": true})
+	if result["reachable"] != true {
+		t.Errorf("dry run not reachable: %v", result["moves"])
+	}
+	if moves := result["moves"].([]interface{}); len(moves) != 3 {
+		t.Errorf("planned %d moves, want 3", len(moves))
+	}
+	if result["note"] != dryRunNote {
+		t.Errorf("note = %v, want the planning limitation", result["note"])
+	}
+
+	after, err := cell.TrueFramePose("gripper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spatialmath.PoseAlmostEqual(before, after) {
+		t.Error("dry run moved the arm")
+	}
+	if cell.Holding() {
+		t.Error("dry run closed the gripper on the object")
+	}
+}
+
+func TestSimMoveTo(t *testing.T) {
+	cfg := sim.DefaultConfig()
+	// Calibration only affects the camera; move_to must not care.
+	cfg.CalibrationError = spatialmath.NewPoseFromPoint(r3.Vector{X: 10})
+	cell := sim.NewCell(cfg)
+	svc := newSimService(t, cell, Config{})
+
+	target := r3.Vector{X: 380, Y: 90, Z: 250}
+	result := runJob(t, svc, map[string]interface{}{
+		"command": "move_to", "x": target.X, "y": target.Y, "z": target.Z, "step_size": 25.0,
+	})
+	assertNear(t, "reported final position", vectorFromMap(t, result["final_position"], "x_mm", "y_mm", "z_mm"), target, 1)
+
+	gripperPose, err := cell.TrueFramePose("gripper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNear(t, "gripper position", gripperPose.Point(), target, 1)
+}
+
+func TestSimSelection(t *testing.T) {
+	// The cube is 40mm tall at (450, 0); the cylinder 60mm tall at (450, 110).
+	cfg := sim.DefaultConfig()
+	cfg.Objects = append(cfg.Objects, sim.Object{
+		Shape: sim.Cylinder, Position: r3.Vector{X: 450, Y: 110}, Size: r3.Vector{X: 50, Z: 60},
+	})
+	svc := newSimService(t, sim.NewCell(cfg), Config{})
+
+	detect := func(cmd map[string]interface{}) []map[string]interface{} {
+		t.Helper()
+		cmd["command"] = "detect"
+		resp := runJob(t, svc, cmd)
+		var objects []map[string]interface{}
+		for _, o := range resp["objects"].([]interface{}) {
+			objects = append(objects, o.(map[string]interface{}))
+		}
+		if len(objects) != 2 {
+			t.Fatalf("detected %d objects, want 2", len(objects))
+		}
+		return objects
+	}
+	firstHeight := func(objects []map[string]interface{}) float64 {
+		return objects[0]["height_above_plane_mm"].(float64)
+	}
+	centerOf := func(o map[string]interface{}) r3.Vector {
+		return vectorFromMap(t, o, "center_x_mm", "center_y_mm", "center_z_mm")
+	}
+
+	objects := detect(map[string]interface{}{})
+	if a, b := centerOf(objects[0]).Norm(), centerOf(objects[1]).Norm(); a > b {
+		t.Errorf("nearest_camera: %.1fmm listed before %.1fmm", a, b)
+	}
+	if objects := detect(map[string]interface{}{"selection": "largest"}); objects[0]["point_count"].(int) < objects[1]["point_count"].(int) {
+		t.Errorf("largest: %v points listed before %v", objects[0]["point_count"], objects[1]["point_count"])
+	}
+	if h := firstHeight(detect(map[string]interface{}{"selection": "highest"})); math.Abs(h-60) > 2 {
+		t.Errorf("highest: first object is %.1fmm tall, want the 60mm cylinder", h)
+	}
+	for _, tc := range []struct {
+		point  []interface{}
+		height float64
+	}{{[]interface{}{450.0, 0.0}, 40}, {[]interface{}{450.0, 120.0}, 60}} {
+		if h := firstHeight(detect(map[string]interface{}{"selection": "nearest_point", "selection_point": tc.point})); math.Abs(h-tc.height) > 2 {
+			t.Errorf("nearest_point %v: first object is %.1fmm tall, want %gmm", tc.point, h, tc.height)
+		}
+	}
+	objects = detect(map[string]interface{}{"selection": "leftmost"})
+	if a, b := centerOf(objects[0]), centerOf(objects[1]); a.X/a.Z > b.X/b.Z {
+		t.Errorf("leftmost: %v listed before %v", a, b)
+	}
+
+	if _, err := svc.DoCommand(context.Background(), map[string]interface{}{"command": "detect", "selection": "nearest_point"}); err == nil {
+		t.Error("nearest_point without a selection_point did not fail")
+	}
+
+	// World-frame detections still rank by where the objects are relative to the camera.
+	// The camera is yawed so the objects are side by side in the image, and looks down from
+	// over the cylinder, which is further from the world origin.
+	cfg.CloudInWorld = true
+	cfg.Home = spatialmath.NewPose(r3.Vector{X: 450, Z: 500}, &spatialmath.OrientationVectorDegrees{OZ: -1, Theta: 90})
+	cell := sim.NewCell(cfg)
+	world := newSimService(t, cell, Config{DetectionFrame: "world"})
+	runJob(t, world, map[string]interface{}{"command": "move_to", "x": 450.0, "y": 110.0, "z": 400.0})
+	camPose, err := cell.TrueFramePose("camera")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inCamera := func(o interface{}) r3.Vector {
+		p := vectorFromMap(t, o, "center_x_mm", "center_y_mm", "center_z_mm")
+		return spatialmath.Compose(spatialmath.PoseInverse(camPose), spatialmath.NewPoseFromPoint(p)).Point()
+	}
+	for _, tc := range []struct {
+		strategy string
+		key      func(r3.Vector) float64
+	}{
+		{"nearest_camera", func(p r3.Vector) float64 { return p.Norm() }},
+		{"leftmost", func(p r3.Vector) float64 { return math.Atan2(p.X, p.Z) }},
+	} {
+		resp := runJob(t, world, map[string]interface{}{"command": "detect", "selection": tc.strategy})
+		objects := resp["objects"].([]interface{})
+		if len(objects) != 2 {
+			t.Fatalf("%s in world frame: detected %d objects, want 2", tc.strategy, len(objects))
+		}
+		if a, b := inCamera(objects[0]), inCamera(objects[1]); tc.key(a) > tc.key(b) {
+			t.Errorf("%s in world frame: camera-frame %v listed before %v", tc.strategy, a, b)
+		}
+	}
+}
+
+func TestSimMultiFrame(t *testing.T) {
+	cfg := sim.DefaultConfig()
+	cfg.DepthNoiseMm = 2
+	cell := sim.NewCell(cfg)
+	svc := newSimService(t, cell, Config{Frames: 8, GraspDepthOffsetMm: -15})
+
+	resp := runJob(t, svc, map[string]interface{}{"command": "detect"})
+	if resp["count"] != 1 {
+		t.Fatalf("detected %v objects, want 1", resp["count"])
+	}
+	obj := resp["objects"].([]interface{})[0].(map[string]interface{})
+	if obj["frames"] != 8 {
+		t.Errorf("frames = %v, want 8", obj["frames"])
+	}
+	if noise := obj["center_std_dev_mm"].(map[string]interface{})["total"].(float64); noise <= 0 || noise > 2 {
+		t.Errorf("center std dev = %.2fmm, want the centroid's share of 2mm depth noise", noise)
+	}
+
+	result := runJob(t, svc, map[string]interface{}{"command": "pick"})
+	if result["success"] != true {
+		t.Fatalf("pick failed: %v", result["verdict"])
+	}
+	noise, ok := result["detection_noise_mm"].(map[string]interface{})
+	if !ok || noise["frames"] != 8 {
+		t.Errorf("detection_noise_mm = %v, want 8 frames", result["detection_noise_mm"])
+	}
+}
+
+func TestAverageSightingsAlignsDims(t *testing.T) {
+	// The second sighting's major and middle axes came out swapped and its middle axis flipped.
+	first := DetectedObject{OrientedBox: OrientedBox{
+		Axes: [3]r3.Vector{{X: 1}, {Y: 1}, {Z: 1}},
+		Dims: [3]float64{42, 40, 20},
+	}}
+	second := DetectedObject{OrientedBox: OrientedBox{
+		Axes: [3]r3.Vector{{Y: 1}, {X: -1}, {Z: 1}},
+		Dims: [3]float64{41, 43, 22},
+	}}
+	got := averageSightings([]DetectedObject{first, second}).OrientedBox
+	if want := [3]float64{42.5, 40.5, 21}; got.Dims != want {
+		t.Errorf("dims = %v, want %v", got.Dims, want)
+	}
+	if got.Axes != first.OrientedBox.Axes {
+		t.Errorf("axes = %v, want the first sighting's", got.Axes)
+	}
+}
+
+func TestSimROI(t *testing.T) {
+	// The cube is 40mm tall at (450, 0); the cylinder 60mm tall at (450, 110).
+	cfg := sim.DefaultConfig()
+	cfg.Objects = append(cfg.Objects, sim.Object{
+		Shape: sim.Cylinder, Position: r3.Vector{X: 450, Y: 110}, Size: r3.Vector{X: 50, Z: 60},
+	})
+	cell := sim.NewCell(cfg)
+
+	for _, tc := range []struct {
+		name   string
+		roi    ROIConfig
+		height float64
+	}{
+		{"world box", ROIConfig{MinMm: []float64{380, -60, -10}, MaxMm: []float64{520, 60, 100}}, 40},
+		{"world polygon", ROIConfig{Polygon: [][]float64{{380, 50}, {520, 50}, {520, 170}, {450, 200}, {380, 170}}}, 60},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			roi := tc.roi
+			svc := newSimService(t, cell, Config{Segmentation: SegmentationConfig{ROI: &roi}})
+			resp := runJob(t, svc, map[string]interface{}{"command": "detect"})
+			if resp["count"] != 1 {
+				t.Fatalf("detected %v objects, want 1", resp["count"])
+			}
+			obj := resp["objects"].([]interface{})[0].(map[string]interface{})
+			if h := obj["height_above_plane_mm"].(float64); math.Abs(h-tc.height) > 2 {
+				t.Errorf("detected a %.1fmm tall object, want the %gmm one", h, tc.height)
+			}
+			crop := resp["roi"].(map[string]interface{})
+			if crop["points_removed"].(int) == 0 {
+				t.Error("no points removed by the crop")
+			}
+			if crop["clusters_removed"] != 1 {
+				t.Errorf("clusters_removed = %v, want 1", crop["clusters_removed"])
+			}
+		})
+	}
+}
+
+func TestSimStaleDetection(t *testing.T) {
+	cell := sim.NewCell(sim.DefaultConfig())
+	svc := newSimService(t, cell, Config{GraspDepthOffsetMm: -15})
+	ctx := context.Background()
+
+	detect := func() map[string]interface{} {
+		t.Helper()
+		resp := runJob(t, svc, map[string]interface{}{"command": "detect"})
+		if resp["count"] != 1 {
+			t.Fatalf("detected %v objects, want 1", resp["count"])
+		}
+		return resp["objects"].([]interface{})[0].(map[string]interface{})
+	}
+	first := detect()
+	assertNear(t, "world position", vectorFromMap(t, first["world_position"], "x_mm", "y_mm", "z_mm"), cell.Objects()[0].Top(), 5)
+	if second := detect(); second["object_id"] != first["object_id"] {
+		t.Errorf("object_id changed from %v to %v between detections of the same object", first["object_id"], second["object_id"])
+	}
+	objectID := float64(first["object_id"].(int))
+	if _, err := svc.DoCommand(ctx, map[string]interface{}{"command": "pick_detected", "object_id": objectID + 1, "dry_run": true}); err == nil {
+		t.Error("pick_detected with an unknown object_id did not fail")
+	}
+
+	// Moving the camera makes the detection stale: refused by default, or re-projected.
+	runJob(t, svc, map[string]interface{}{"command": "move_to", "x": 420.0, "y": 30.0, "z": 330.0})
+	_, err := svc.DoCommand(ctx, map[string]interface{}{"command": "pick_detected", "object_id": objectID})
+	if err == nil || !strings.Contains(err.Error(), "has moved") {
+		t.Fatalf("pick_detected after moving the camera: err = %v, want a refusal", err)
+	}
+	result := runJob(t, svc, map[string]interface{}{"command": "pick_detected", "object_id": objectID, "stale_detection": "reproject"})
+	if result["success"] != true {
+		t.Fatalf("re-projected pick failed: %v", result["verdict"])
+	}
+	detection := result["detection"].(map[string]interface{})
+	if detection["reprojected"] != true {
+		t.Errorf("detection = %v, want reprojected", detection)
+	}
+	if moved := detection["camera_moved_mm"].(float64); moved < 30 {
+		t.Errorf("camera_moved_mm = %.1f, want the ~40mm move", moved)
+	}
+	cell.ResetArm()
+
+	// Object IDs carry over without Validate too, as when the CLI builds the service with
+	// only the segmentation flags set.
+	unvalidated := newUnvalidatedSimService(t, cell, Config{Segmentation: SegmentationConfig{
+		MinPtsInPlane: 1500, MaxDistFromPlane: 5, AngleTolerance: 20, MinPtsInSegment: 100, ClusteringRadiusMm: 5, MeanKFiltering: 50,
+	}})
+	ids := make([]interface{}, 2)
+	for i := range ids {
+		// Seen from another pose, the object lands a little elsewhere in world.
+		if i > 0 {
+			runJob(t, unvalidated, map[string]interface{}{"command": "move_to", "x": 420.0, "y": 30.0, "z": 330.0})
+		}
+		resp := runJob(t, unvalidated, map[string]interface{}{"command": "detect"})
+		ids[i] = resp["objects"].([]interface{})[0].(map[string]interface{})["object_id"]
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("without Validate: object_id changed from %v to %v between detections of the same object", ids[0], ids[1])
+	}
+	cell.ResetArm()
+
+	aging := newSimService(t, cell, Config{MaxDetectionAgeSec: 0.05})
+	runJob(t, aging, map[string]interface{}{"command": "detect"})
+	time.Sleep(100 * time.Millisecond)
+	if _, err := aging.DoCommand(ctx, map[string]interface{}{"command": "pick_detected"}); err == nil || !strings.Contains(err.Error(), "old") {
+		t.Errorf("pick_detected of an old detection: err = %v, want a refusal", err)
+	}
+}
+
+func TestSimRedetectMatching(t *testing.T) {
+	// A second object in view at the approach may be listed first; the match must not be.
+	cfg := sim.DefaultConfig()
+	cfg.Objects = append(cfg.Objects, sim.Object{
+		Shape: sim.Cylinder, Position: r3.Vector{X: 450, Y: 110}, Size: r3.Vector{X: 50, Z: 60},
+	})
+	cell := sim.NewCell(cfg)
+	svc := newSimService(t, cell, Config{GraspDepthOffsetMm: -15})
+
+	detectCube := func() float64 {
+		t.Helper()
+		resp := runJob(t, svc, map[string]interface{}{"command": "detect"})
+		for i, o := range resp["objects"].([]interface{}) {
+			if math.Abs(o.(map[string]interface{})["center_y_mm"].(float64)) < 30 {
+				return float64(i)
+			}
+		}
+		t.Fatal("cube not detected")
+		return 0
+	}
+	result := runJob(t, svc, map[string]interface{}{"command": "pick_detected", "object_index": detectCube()})
+	if result["redetect_matched"] != true {
+		t.Fatalf("redetect_matched = %v, want true", result["redetect_matched"])
+	}
+	if d := result["redetect_match_distance_mm"].(float64); d > 5 {
+		t.Errorf("matched %.1fmm away, want < 5mm", d)
+	}
+	cell.ResetArm()
+
+	// The match gate defaults without Validate, as when the CLI builds the service.
+	unvalidated := newUnvalidatedSimService(t, cell, Config{GraspDepthOffsetMm: -15})
+	if result := runJob(t, unvalidated, map[string]interface{}{"command": "pick"}); result["redetect_matched"] != true {
+		t.Errorf("without Validate: redetect_matched = %v, want true", result["redetect_matched"])
+	}
+	cell.ResetArm()
+
+	// With the cube gone before the approach, only the cylinder is seen, too far away to be it.
+	index := detectCube()
+	if err := cell.MoveObject(0, 250, -300); err != nil {
+		t.Fatal(err)
+	}
+	result = runJob(t, svc, map[string]interface{}{"command": "pick_detected", "object_index": index})
+	if result["redetect_matched"] != false {
+		t.Errorf("redetect_matched = %v, want false", result["redetect_matched"])
+	}
+	if _, reported := result["redetect_match_distance_mm"]; reported {
+		t.Error("match distance reported without a match")
+	}
+}
+
+func TestSimTouch(t *testing.T) {
+	// A probe-only arm: the sim's gripper frame stands in for the probe tip.
+	cell := sim.NewCell(sim.DefaultConfig())
+	svc := newSimService(t, cell, Config{ToolFrame: "gripper", TouchStandoffMm: 5})
+
+	if _, err := svc.DoCommand(context.Background(), map[string]interface{}{"command": "pick"}); err == nil {
+		t.Error("pick without a gripper did not fail")
+	}
+
+	result := runJob(t, svc, map[string]interface{}{"command": "touch"})
+	if result["success"] != true {
+		t.Fatalf("touch failed: %v", result["verdict"])
+	}
+	top := cell.Objects()[0].Top()
+	assertNear(t, "surface point", vectorFromMap(t, result["surface_point_world_frame"], "x_mm", "y_mm", "z_mm"), top, 1.5)
+	target := vectorFromMap(t, result["target_world_frame"], "x_mm", "y_mm", "z_mm")
+	assertNear(t, "tool position", vectorFromMap(t, result["tool_position_world_frame"], "x_mm", "y_mm", "z_mm"), target, 0.5)
+	if d := target.Z - vectorFromMap(t, result["surface_point_world_frame"], "x_mm", "y_mm", "z_mm").Z; math.Abs(d-5) > 0.01 {
+		t.Errorf("target %.2fmm above the surface, want the 5mm stand-off", d)
+	}
+
+	// The probe retracts along the normal to the approach height.
+	probePose, err := cell.TrueFramePose("gripper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNear(t, "retracted probe", probePose.Point(), target.Add(r3.Vector{Z: svc.cfg.ApproachOffsetMm}), 1)
+}
+
+func TestSimSensitivity(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	t.Run("pick", func(t *testing.T) {
+		report, err := runSensitivity(context.Background(), sensitivityOptions{
+			Mode: sensitivityPick, TranslationsMm: []float64{5}, Axes: []string{"x"}, Picks: 3, ToleranceMm: 1,
+		}, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Baseline.Succeeded != 3 || report.Baseline.Detected {
+			t.Fatalf("baseline: %d/3 picks succeeded, detected=%v", report.Baseline.Succeeded, report.Baseline.Detected)
+		}
+		row := report.Rows[0]
+		// A translation shifts every point equally, wherever the arm is looking from.
+		if math.Abs(vecNorm(row.ExpectedOffsetMm)-5) > 0.01 {
+			t.Errorf("expected offset %.2fmm, want 5mm", vecNorm(row.ExpectedOffsetMm))
+		}
+		assertNear(t, "place offset", row.PlaceOffsetMm, row.ExpectedOffsetMm, 0.5)
+		if !row.Detected || !row.Recovered {
+			t.Errorf("5mm translation: detected=%v recovered=%v", row.Detected, row.Recovered)
+		}
+		if row.Correction == nil || row.Correction.TranslationErrorMm > 0.5 {
+			t.Errorf("correction did not recover the mount: %+v (%s)", row.Correction, row.CorrectionError)
+		}
+	})
+	t.Run("sweep", func(t *testing.T) {
+		report, err := runSensitivity(context.Background(), sensitivityOptions{
+			Mode: sensitivitySweep, RotationsDeg: []float64{1}, Axes: []string{"y"}, SweepPoses: 6, ToleranceMm: 1,
+		}, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Baseline.Detected || !report.Rows[0].Detected {
+			t.Errorf("baseline detected=%v, 1deg rotation detected=%v", report.Baseline.Detected, report.Rows[0].Detected)
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// What pick_detected does with a detection taken from a camera pose it is no longer at:
+// refuse it, or re-project its objects into the camera's current pose.
+const (
+	staleRefuse    = "refuse"
+	staleReproject = "reproject"
+)
+
+func validateStaleDetection(policy string) error {
+	switch policy {
+	case "", staleRefuse, staleReproject:
+		return nil
+	default:
+		return fmt.Errorf("stale_detection must be %q or %q, got %q", staleRefuse, staleReproject, policy)
+	}
+}
+
+const (
+	// cameraMovedMm and cameraMovedDeg are how far the detection frame may have moved
+	// since a detection before pick_detected treats it as stale.
+	cameraMovedMm  = 1.0
+	cameraMovedDeg = 0.5
+)
+
+// detectionSnapshot is a detection kept for pick_detected, with what is needed to tell
+// whether it still describes the scene: when it was taken, and where the detection frame
+// was in world at the time.
+type detectionSnapshot struct {
+	Seq   int
+	Time  time.Time
+	Frame string
+	// FramePose is the detection frame's world pose at capture (nil if it was unknown).
+	FramePose spatialmath.Pose
+	Objects   []DetectedObject
+	// ObjectIDs identify objects across detections: an object seen again where it was
+	// last detected keeps its ID. World holds the objects' centers in world frame at
+	// capture, if FramePose is known.
+	ObjectIDs []int
+	World     []r3.Vector
+}
+
+// storeDetection keeps objects detected at captured as the last detection, converting
+// them to world frame while the camera is still where it saw them.
+func (s *handEyeTest) storeDetection(ctx context.Context, objects []DetectedObject, captured time.Time) *detectionSnapshot {
+	frame := s.cfg.detectionFrame()
+	snap := &detectionSnapshot{Time: captured, Frame: frame, Objects: objects, ObjectIDs: make([]int, len(objects))}
+	if pose, err := s.frameWorldPose(ctx, frame); err != nil {
+		s.logger.Warnf("Could not record the detection frame's world pose (non-fatal): %v", err)
+	} else {
+		snap.FramePose = pose
+		for _, obj := range objects {
+			snap.World = append(snap.World, spatialmath.Compose(pose, spatialmath.NewPoseFromPoint(obj.Center)).Point())
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detectionSeq++
+	snap.Seq = s.detectionSeq
+	previous := s.lastDetection
+	taken := map[int]bool{}
+	for i, obj := range objects {
+		id := -1
+		if previous != nil && previous.World != nil && snap.World != nil {
+			id = previous.matchObject(obj, snap.World[i], s.cfg.RedetectGateMm, taken)
+		}
+		if id < 0 {
+			s.objectCounter++
+			id = s.objectCounter
+		}
+		taken[id] = true
+		snap.ObjectIDs[i] = id
+	}
+	s.lastDetection = snap
+	return snap
+}
+
+// matchObject returns the ID of the nearest object in the snapshot that is within gateMm
+// of world, is of a similar size and has the same marker ID, skipping taken IDs. It
+// returns -1 if there is none.
+func (snap *detectionSnapshot) matchObject(obj DetectedObject, world r3.Vector, gateMm float64, taken map[int]bool) int {
+	match, bestDist := -1, math.Inf(1)
+	for i, prev := range snap.Objects {
+		if taken[snap.ObjectIDs[i]] {
+			continue
+		}
+		if (prev.ID == nil) != (obj.ID == nil) || (prev.ID != nil && *prev.ID != *obj.ID) {
+			continue
+		}
+		if !similarSize(prev.OrientedBox, obj.OrientedBox) {
+			continue
+		}
+		if d := vecNorm(snap.World[i].Sub(world)); d <= gateMm && d < bestDist {
+			match, bestDist = snap.ObjectIDs[i], d
+		}
+	}
+	return match
+}
+
+// toMap adds the snapshot's timing, camera pose and object IDs to a detection response.
+func (snap *detectionSnapshot) toMap(resp map[string]interface{}) map[string]interface{} {
+	resp["detection_id"] = snap.Seq
+	resp["timestamp"] = snap.Time.UTC().Format(time.RFC3339Nano)
+	if snap.FramePose != nil {
+		resp["detection_frame_world_pose"] = poseToMap(snap.FramePose)
+	}
+	for i, entry := range resp["objects"].([]interface{}) {
+		obj := entry.(map[string]interface{})
+		obj["object_id"] = snap.ObjectIDs[i]
+		if snap.World != nil {
+			w := snap.World[i]
+			obj["world_position"] = map[string]interface{}{"x_mm": w.X, "y_mm": w.Y, "z_mm": w.Z, "frame": "world"}
+		}
+	}
+	return resp
+}
+
+// detectedObject returns an object from the last detection, by object ID if objectID is
+// not negative and by index otherwise. It refuses detections older than
+// max_detection_age_sec. If the detection frame has moved since, it refuses the
+// detection or, with the reproject policy, moves the object into the frame's current
+// pose. The returned map describes the detection for the result.
+func (s *handEyeTest) detectedObject(
+	ctx context.Context, objectIndex, objectID int, policy string,
+) (DetectedObject, map[string]interface{}, error) {
+	s.mu.Lock()
+	snap := s.lastDetection
+	s.mu.Unlock()
+
+	if snap == nil {
+		return DetectedObject{}, nil, fmt.Errorf("no previous detection; run 'detect' first")
+	}
+	if objectID >= 0 {
+		objectIndex = -1
+		for i, id := range snap.ObjectIDs {
+			if id == objectID {
+				objectIndex = i
+			}
+		}
+		if objectIndex < 0 {
+			return DetectedObject{}, nil, fmt.Errorf("object_id %d is not in the last detection (%d); run 'detect' again", objectID, snap.Seq)
+		}
+	}
+	if objectIndex >= len(snap.Objects) {
+		return DetectedObject{}, nil, fmt.Errorf("object_index %d out of range (detected %d objects)", objectIndex, len(snap.Objects))
+	}
+	obj := snap.Objects[objectIndex]
+	age := time.Since(snap.Time)
+	info := map[string]interface{}{
+		"detection_id": snap.Seq,
+		"object_id":    snap.ObjectIDs[objectIndex],
+		"age_sec":      age.Seconds(),
+		"reprojected":  false,
+	}
+	if s.cfg.MaxDetectionAgeSec > 0 && age.Seconds() > s.cfg.MaxDetectionAgeSec {
+		return DetectedObject{}, nil, fmt.Errorf("detection %d is %.1fs old, more than max_detection_age_sec (%gs); run 'detect' again",
+			snap.Seq, age.Seconds(), s.cfg.MaxDetectionAgeSec)
+	}
+	if snap.Frame == "world" {
+		return obj, info, nil
+	}
+
+	if snap.FramePose == nil {
+		return DetectedObject{}, nil, fmt.Errorf("the %s world pose at detection %d is unknown, so it cannot be checked for motion; run 'detect' again",
+			snap.Frame, snap.Seq)
+	}
+	current, err := s.frameWorldPose(ctx, snap.Frame)
+	if err != nil {
+		return DetectedObject{}, nil, err
+	}
+	// How the detection frame moved, expressed in its current pose.
+	moved := spatialmath.Compose(spatialmath.PoseInverse(current), snap.FramePose)
+	movedMm := vecNorm(moved.Point())
+	movedDeg := moved.Orientation().AxisAngles().Theta * 180 / math.Pi
+	info["camera_moved_mm"] = movedMm
+	info["camera_rotated_deg"] = movedDeg
+	if movedMm <= cameraMovedMm && movedDeg <= cameraMovedDeg {
+		return obj, info, nil
+	}
+	if policy != staleReproject {
+		return DetectedObject{}, nil, fmt.Errorf(
+			"%s has moved %.1fmm and %.1f deg since detection %d; run 'detect' again or use stale_detection %q",
+			snap.Frame, movedMm, movedDeg, snap.Seq, staleReproject)
+	}
+	s.logger.Infof("%s has moved %.1fmm and %.1f deg since detection %d, re-projecting object %d",
+		snap.Frame, movedMm, movedDeg, snap.Seq, snap.ObjectIDs[objectIndex])
+	info["reprojected"] = true
+	return transformObject(obj, moved), info, nil
+}
+
+// transformObject re-expresses an object's geometry through a rigid transform.
+func transformObject(obj DetectedObject, t spatialmath.Pose) DetectedObject {
+	apply := func(p r3.Vector) r3.Vector {
+		return spatialmath.Compose(t, spatialmath.NewPoseFromPoint(p)).Point()
+	}
+	out := obj
+	out.Center = apply(obj.Center)
+	corners := make([]r3.Vector, 0, 8)
+	for _, x := range []float64{obj.BoundingBox.Min.X, obj.BoundingBox.Max.X} {
+		for _, y := range []float64{obj.BoundingBox.Min.Y, obj.BoundingBox.Max.Y} {
+			for _, z := range []float64{obj.BoundingBox.Min.Z, obj.BoundingBox.Max.Z} {
+				corners = append(corners, apply(r3.Vector{X: x, Y: y, Z: z}))
+			}
+		}
+	}
+	out.BoundingBox = computeBoundingBox(corners)
+	out.OrientedBox.Center = apply(obj.OrientedBox.Center)
+	for i, axis := range obj.OrientedBox.Axes {
+		out.OrientedBox.Axes[i] = rotate(t.Orientation(), axis)
+	}
+	if obj.Plane != nil {
+		normal := rotate(t.Orientation(), obj.Plane.Normal)
+		onPlane := apply(obj.Plane.Normal.Mul(-obj.Plane.Offset))
+		out.Plane = &groundPlane{Normal: normal, Offset: -normal.Dot(onPlane)}
+	}
+	if obj.Orientation != nil {
+		out.Orientation = spatialmath.Compose(t, spatialmath.NewPose(obj.Center, obj.Orientation)).Orientation()
+	}
+	return out
+}
@@ -0,0 +1,139 @@
+package handeyetest
+
+import (
+	"math"
+	"sort"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// vectorStats summarizes the spread of a set of 3D positions.
+type vectorStats struct {
+	Count          int
+	Mean           r3.Vector
+	StdDev         r3.Vector
+	MaxDeviationMm float64
+}
+
+func computeVectorStats(points []r3.Vector) vectorStats {
+	stats := vectorStats{Count: len(points)}
+	if len(points) == 0 {
+		return stats
+	}
+	n := float64(len(points))
+	for _, p := range points {
+		stats.Mean = stats.Mean.Add(p)
+	}
+	stats.Mean = stats.Mean.Mul(1 / n)
+
+	var variance r3.Vector
+	for _, p := range points {
+		d := p.Sub(stats.Mean)
+		variance = variance.Add(r3.Vector{X: d.X * d.X, Y: d.Y * d.Y, Z: d.Z * d.Z})
+		stats.MaxDeviationMm = math.Max(stats.MaxDeviationMm, vecNorm(d))
+	}
+	variance = variance.Mul(1 / n)
+	stats.StdDev = r3.Vector{X: math.Sqrt(variance.X), Y: math.Sqrt(variance.Y), Z: math.Sqrt(variance.Z)}
+	return stats
+}
+
+func (vs vectorStats) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"count": vs.Count,
+		"mean": map[string]interface{}{
+			"x_mm": vs.Mean.X, "y_mm": vs.Mean.Y, "z_mm": vs.Mean.Z,
+		},
+		"std_dev_mm": map[string]interface{}{
+			"x": vs.StdDev.X, "y": vs.StdDev.Y, "z": vs.StdDev.Z,
+			"total": vecNorm(vs.StdDev),
+		},
+		"max_deviation_mm": vs.MaxDeviationMm,
+	}
+}
+
+// orientationDeviations returns how far, in degrees, each orientation is from their mean.
+// The mean is taken over rotation vectors relative to the first orientation, which is
+// accurate for spreads of a few degrees.
+func orientationDeviations(orientations []spatialmath.Orientation) scalarSeries {
+	vecs := rotationVectors(orientations)
+	mean := computeVectorStats(vecs).Mean
+	devs := make(scalarSeries, len(vecs))
+	for i, v := range vecs {
+		devs[i] = vecNorm(v.Sub(mean))
+	}
+	return devs
+}
+
+// meanOrientation averages orientations the same way as orientationDeviations.
+func meanOrientation(orientations []spatialmath.Orientation) spatialmath.Orientation {
+	mean := computeVectorStats(rotationVectors(orientations)).Mean
+	angle := vecNorm(mean)
+	if angle == 0 {
+		return orientations[0]
+	}
+	axis := mean.Mul(1 / angle)
+	delta := &spatialmath.R4AA{Theta: angle * math.Pi / 180, RX: axis.X, RY: axis.Y, RZ: axis.Z}
+	return spatialmath.Compose(spatialmath.NewPoseFromOrientation(delta),
+		spatialmath.NewPoseFromOrientation(orientations[0])).Orientation()
+}
+
+// rotationVectors returns each orientation's rotation from the first, as axis times
+// angle in degrees, in the frame the orientations are expressed in.
+func rotationVectors(orientations []spatialmath.Orientation) []r3.Vector {
+	vecs := make([]r3.Vector, len(orientations))
+	for i, o := range orientations {
+		aa := spatialmath.OrientationBetween(orientations[0], o).AxisAngles()
+		vecs[i] = r3.Vector{X: aa.RX, Y: aa.RY, Z: aa.RZ}.Mul(aa.Theta * 180 / math.Pi)
+	}
+	return vecs
+}
+
+// scalarSeries accumulates values for mean/std-dev/percentile summaries.
+type scalarSeries []float64
+
+// percentile returns the p-th percentile (0-100) of the sorted values, interpolating
+// linearly between neighbouring ranks.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := rank - float64(lo)
+	return sorted[lo] + frac*(sorted[lo+1]-sorted[lo])
+}
+
+func (ss scalarSeries) toMap() map[string]interface{} {
+	if len(ss) == 0 {
+		return map[string]interface{}{"count": 0}
+	}
+	var sum float64
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	for _, v := range ss {
+		sum += v
+		minV = math.Min(minV, v)
+		maxV = math.Max(maxV, v)
+	}
+	mean := sum / float64(len(ss))
+	var variance float64
+	for _, v := range ss {
+		variance += (v - mean) * (v - mean)
+	}
+	sorted := append([]float64{}, ss...)
+	sort.Float64s(sorted)
+	return map[string]interface{}{
+		"count":   len(ss),
+		"mean":    mean,
+		"std_dev": math.Sqrt(variance / float64(len(ss))),
+		"min":     minV,
+		"max":     maxV,
+		"p50":     percentile(sorted, 50),
+		"p90":     percentile(sorted, 90),
+		"p95":     percentile(sorted, 95),
+	}
+}
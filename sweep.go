@@ -0,0 +1,220 @@
+package handeyetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// observationPoses returns the world-frame gripper poses to visit during a sweep.
+func (sc *SweepConfig) observationPoses() ([]spatialmath.Pose, error) {
+	if len(sc.Poses) > 0 {
+		poses := make([]spatialmath.Pose, len(sc.Poses))
+		for i, p := range sc.Poses {
+			poses[i] = p.pose()
+		}
+		return poses, nil
+	}
+	if err := sc.validate(); err != nil {
+		return nil, err
+	}
+
+	// Ring of poses around and above the target, each looking straight at it.
+	target := r3.Vector{X: sc.Target[0], Y: sc.Target[1], Z: sc.Target[2]}
+	poses := make([]spatialmath.Pose, sc.Count)
+	for i := range poses {
+		angle := 2 * math.Pi * float64(i) / float64(sc.Count)
+		pos := r3.Vector{
+			X: target.X + sc.RadiusMm*math.Cos(angle),
+			Y: target.Y + sc.RadiusMm*math.Sin(angle),
+			Z: target.Z + sc.HeightMm,
+		}
+		dir := target.Sub(pos).Normalize()
+		poses[i] = spatialmath.NewPose(pos, &spatialmath.OrientationVectorDegrees{OX: dir.X, OY: dir.Y, OZ: dir.Z})
+	}
+	return poses, nil
+}
+
+// parseSweepConfig overrides fields of the configured sweep with any matching keys in
+// the DoCommand (e.g. "poses", "target", "count"), and checks the result.
+func parseSweepConfig(cmd map[string]interface{}, defaults SweepConfig) (SweepConfig, error) {
+	// Unmarshal reuses a slice's backing array, so copy them to keep the defaults intact.
+	sweepCfg := defaults
+	sweepCfg.Poses = append([]PoseConfig(nil), defaults.Poses...)
+	sweepCfg.Target = append([]float64(nil), defaults.Target...)
+	raw, err := json.Marshal(cmd)
+	if err != nil {
+		return sweepCfg, err
+	}
+	if err := json.Unmarshal(raw, &sweepCfg); err != nil {
+		return sweepCfg, fmt.Errorf("invalid sweep parameters: %w", err)
+	}
+	return sweepCfg, sweepCfg.validate()
+}
+
+func poseToMap(p spatialmath.Pose) map[string]interface{} {
+	pt := p.Point()
+	ov := p.Orientation().OrientationVectorDegrees()
+	return map[string]interface{}{
+		"x_mm": pt.X, "y_mm": pt.Y, "z_mm": pt.Z,
+		"o_x": ov.OX, "o_y": ov.OY, "o_z": ov.OZ, "theta": ov.Theta,
+	}
+}
+
+// handleSweep moves the gripper through each observation pose, detects the target from
+// every viewpoint and reports how tightly its apparent world position clusters. With a
+// good calibration the spread is limited to sensor noise; rotational mount errors show
+// up as positions that drift with the viewpoint.
+func (s *handEyeTest) handleSweep(ctx context.Context, sweepCfg SweepConfig, objectIndex int, sel selection) (map[string]interface{}, error) {
+	poses, err := sweepCfg.observationPoses()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.currentStatus = "sweeping"
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.currentStatus = "idle"
+		s.mu.Unlock()
+	}()
+
+	stats, orientationDevs, rows, err := s.sweep(ctx, poses, objectIndex, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("Sweep complete: %d/%d poses observed the target, std-dev %.2fmm, max deviation %.2fmm",
+		stats.Count, len(poses), vecNorm(stats.StdDev), stats.MaxDeviationMm)
+
+	result := map[string]interface{}{
+		"success":        stats.Count >= 2,
+		"poses":          len(poses),
+		"observed":       stats.Count,
+		"world_position": stats.toMap(),
+		"observations":   rows,
+	}
+	if len(orientationDevs) > 0 {
+		result["orientation_deviation_deg"] = orientationDevs.toMap()
+	}
+	s.mu.Lock()
+	s.lastResult = result
+	s.mu.Unlock()
+	s.recordHistory("sweep", result)
+	return result, nil
+}
+
+// sweep visits each pose and detects the target from it, returning the spread of the
+// target's world positions and one row per pose. If the detector measures the target's
+// orientation (a marker), it also returns how far each sighting's world orientation is
+// from their mean.
+func (s *handEyeTest) sweep(ctx context.Context, poses []spatialmath.Pose, objectIndex int, sel selection) (vectorStats, scalarSeries, []interface{}, error) {
+	detectionFrame := s.cfg.detectionFrame()
+	var observations []r3.Vector
+	var orientations []spatialmath.Orientation
+	var observedRows []map[string]interface{}
+	var targetID *int
+	rows := make([]interface{}, 0, len(poses))
+
+	// observe moves to one pose and records what it sees in the pose's row.
+	observe := func(i int, pose spatialmath.Pose, row map[string]interface{}) {
+		s.logger.Infof("Sweep pose %d/%d: moving to (%.1f, %.1f, %.1f)...",
+			i+1, len(poses), pose.Point().X, pose.Point().Y, pose.Point().Z)
+		success, err := s.motion.Move(ctx, motion.MoveReq{
+			ComponentName: s.cfg.toolFrame(),
+			Destination:   referenceframe.NewPoseInFrame("world", pose),
+		})
+		if err != nil {
+			row["error"] = fmt.Sprintf("move failed: %v", err)
+			return
+		}
+		if !success {
+			row["error"] = "motion planner could not find path"
+			return
+		}
+
+		objects, _, err := s.detectSelected(ctx, sel)
+		if err != nil {
+			row["error"] = fmt.Sprintf("detection failed: %v", err)
+			return
+		}
+
+		// The first sighting picks the target by index; later poses follow the object
+		// nearest to where it has been seen so far, and with the same marker ID if it has one.
+		mean := computeVectorStats(observations).Mean
+		var best r3.Vector
+		var bestObj DetectedObject
+		bestDist := math.Inf(1)
+		for j, obj := range objects {
+			if targetID != nil && (obj.ID == nil || *obj.ID != *targetID) {
+				continue
+			}
+			worldPos, err := s.toWorldFrame(ctx, obj.Center, detectionFrame)
+			if err != nil {
+				row["error"] = err.Error()
+				break
+			}
+			if len(observations) == 0 {
+				if j == objectIndex {
+					best, bestObj, bestDist = worldPos, obj, 0
+				}
+				continue
+			}
+			if d := vecNorm(worldPos.Sub(mean)); d < bestDist {
+				best, bestObj, bestDist = worldPos, obj, d
+			}
+		}
+		if math.IsInf(bestDist, 1) {
+			if _, failed := row["error"]; !failed {
+				row["error"] = fmt.Sprintf("target not detected (%d objects)", len(objects))
+			}
+			return
+		}
+
+		if len(observations) == 0 {
+			targetID = bestObj.ID
+		}
+		observations = append(observations, best)
+		observedRows = append(observedRows, row)
+		row["object_position_world_frame"] = map[string]interface{}{
+			"x_mm": best.X, "y_mm": best.Y, "z_mm": best.Z, "frame": "world",
+		}
+		if bestObj.Orientation != nil {
+			if detectionWorld, err := s.frameWorldPose(ctx, detectionFrame); err == nil {
+				orientation := spatialmath.Compose(detectionWorld, spatialmath.NewPoseFromOrientation(bestObj.Orientation)).Orientation()
+				orientations = append(orientations, orientation)
+				row["orientation_world_frame"] = orientationToMap(orientation)
+			}
+		}
+		s.logger.Infof("Sweep pose %d/%d: object at world (%.1f, %.1f, %.1f)mm",
+			i+1, len(poses), best.X, best.Y, best.Z)
+	}
+	for i, pose := range poses {
+		row := map[string]interface{}{"index": i, "pose": poseToMap(pose)}
+		rows = append(rows, row)
+		observe(i, pose, row)
+		s.reportProgress(ctx, fmt.Sprintf("pose_%d", i+1))
+	}
+
+	stats := computeVectorStats(observations)
+	for k, obs := range observations {
+		observedRows[k]["deviation_mm"] = vecNorm(obs.Sub(stats.Mean))
+	}
+	// Orientation is only compared when every sighting measured it.
+	var orientationDevs scalarSeries
+	if len(orientations) > 0 && len(orientations) == len(observations) {
+		orientationDevs = orientationDeviations(orientations)
+		for k, dev := range orientationDevs {
+			observedRows[k]["orientation_deviation_deg"] = dev
+		}
+	}
+	return stats, orientationDevs, rows, nil
+}
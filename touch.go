@@ -0,0 +1,210 @@
+package handeyetest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// touchResult is the outcome of touching an object's top surface with the tool tip.
+type touchResult struct {
+	Success                bool
+	DetectedPosition       r3.Vector
+	DetectionFrame         string
+	ToolFrame              string
+	StandoffMm             float64
+	SurfacePointWorldFrame r3.Vector
+	TargetWorldFrame       r3.Vector
+	ToolPositionWorldFrame r3.Vector
+	// WorldFrameErrorMm is where the tool tip ended up relative to the target, the surface
+	// point raised by the stand-off.
+	WorldFrameErrorMm r3.Vector
+	StepsCompleted    []string
+	Verdict           verdict
+
+	measured bool
+}
+
+func (r *touchResult) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"success": r.Success,
+		"detected_position": map[string]interface{}{
+			"x_mm": r.DetectedPosition.X, "y_mm": r.DetectedPosition.Y,
+			"z_mm": r.DetectedPosition.Z, "frame": r.DetectionFrame,
+		},
+		"surface_point_world_frame": map[string]interface{}{
+			"x_mm": r.SurfacePointWorldFrame.X, "y_mm": r.SurfacePointWorldFrame.Y,
+			"z_mm": r.SurfacePointWorldFrame.Z, "frame": "world",
+		},
+		"target_world_frame": map[string]interface{}{
+			"x_mm": r.TargetWorldFrame.X, "y_mm": r.TargetWorldFrame.Y,
+			"z_mm": r.TargetWorldFrame.Z, "frame": "world",
+		},
+		"tool_frame":      r.ToolFrame,
+		"standoff_mm":     r.StandoffMm,
+		"steps_completed": r.StepsCompleted,
+		"verdict":         r.Verdict.toMap(),
+	}
+	if r.measured {
+		m["tool_position_world_frame"] = map[string]interface{}{
+			"x_mm": r.ToolPositionWorldFrame.X, "y_mm": r.ToolPositionWorldFrame.Y,
+			"z_mm": r.ToolPositionWorldFrame.Z, "frame": "world",
+		}
+		m["world_frame_error_mm"] = map[string]interface{}{
+			"x": r.WorldFrameErrorMm.X, "y": r.WorldFrameErrorMm.Y, "z": r.WorldFrameErrorMm.Z,
+			"total": vecNorm(r.WorldFrameErrorMm),
+		}
+	}
+	return m
+}
+
+func (r *touchResult) stepDone(ctx context.Context, s *handEyeTest, step string) {
+	r.StepsCompleted = append(r.StepsCompleted, step)
+	s.reportProgress(ctx, step)
+}
+
+// handleTouch detects objects and touches one with the tool tip. It needs no gripper, so
+// it validates calibration on arms that carry only a probe.
+func (s *handEyeTest) handleTouch(ctx context.Context, objectIndex int, sel selection, standoffMm float64) (map[string]interface{}, error) {
+	s.mu.Lock()
+	s.currentStatus = "detecting"
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.currentStatus = "idle"
+		s.mu.Unlock()
+	}()
+
+	captured := time.Now()
+	objects, _, err := s.detectSelected(ctx, sel)
+	if err != nil {
+		return nil, fmt.Errorf("detection failed: %w", err)
+	}
+	s.storeDetection(ctx, objects, captured)
+	if objectIndex >= len(objects) {
+		return nil, fmt.Errorf("object_index %d out of range (detected %d objects)", objectIndex, len(objects))
+	}
+
+	res, err := s.executeTouch(ctx, objects[objectIndex], standoffMm)
+	var result map[string]interface{}
+	if res != nil {
+		result = res.toMap()
+	}
+	s.mu.Lock()
+	s.lastResult = result
+	s.mu.Unlock()
+	s.recordHistory("touch", result)
+	return result, err
+}
+
+// executeTouch moves the tool tip to stand-off above the center of the object's top
+// surface, pointing down into it, measures where the tip really is, and retracts. The
+// descent and retreat are straight lines along the surface normal.
+func (s *handEyeTest) executeTouch(ctx context.Context, obj DetectedObject, standoffMm float64) (*touchResult, error) {
+	s.mu.Lock()
+	s.currentStatus = "touching"
+	s.mu.Unlock()
+
+	detectionFrame := s.cfg.detectionFrame()
+	result := &touchResult{
+		DetectedPosition: obj.Center,
+		DetectionFrame:   detectionFrame,
+		ToolFrame:        s.cfg.toolFrame(),
+		StandoffMm:       standoffMm,
+	}
+
+	// Locate the surface in world now, while the camera is still where it saw the object.
+	top, ok := obj.topPoint()
+	if !ok {
+		if detectionFrame != "world" {
+			return nil, fmt.Errorf("cannot locate the object's top surface: no ground plane or marker was found")
+		}
+		// Without a plane, world Z is up and the cluster's highest point is its top.
+		top = r3.Vector{X: obj.Center.X, Y: obj.Center.Y, Z: obj.BoundingBox.Max.Z}
+	}
+	surface, err := s.toWorldFrame(ctx, top, detectionFrame)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transform object to world frame: %w", err)
+	}
+	up, tableFound := s.tableUp(ctx, obj, detectionFrame)
+	if !tableFound {
+		up = r3.Vector{Z: 1}
+	}
+	result.SurfacePointWorldFrame = surface
+	result.TargetWorldFrame = surface.Add(up.Mul(standoffMm))
+	s.logger.Infof("Touching object at world (%.1f, %.1f, %.1f)mm with %s, %.1fmm stand-off",
+		surface.X, surface.Y, surface.Z, result.ToolFrame, standoffMm)
+
+	// Point the tool down the normal, keeping its current rotation about it.
+	orientation := &spatialmath.OrientationVectorDegrees{OX: -up.X, OY: -up.Y, OZ: -up.Z}
+	if toolPose, err := s.motion.GetPose(ctx, result.ToolFrame, "world", nil, nil); err != nil {
+		s.logger.Warnf("Could not get tool world pose for orientation (non-fatal): %v", err)
+	} else {
+		orientation.Theta = toolPose.Pose().Orientation().OrientationVectorDegrees().Theta
+	}
+
+	// Step 1: Move above the target using motion planning (obstacle-aware)
+	approach := result.TargetWorldFrame.Add(up.Mul(s.cfg.ApproachOffsetMm))
+	s.logger.Infof("Moving to approach position (%.0fmm above target) via motion planning...", s.cfg.ApproachOffsetMm)
+	success, err := s.motion.Move(ctx, motion.MoveReq{
+		ComponentName: result.ToolFrame,
+		Destination:   referenceframe.NewPoseInFrame("world", spatialmath.NewPose(approach, orientation)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to move to approach position: %w", err)
+	}
+	if !success {
+		return nil, fmt.Errorf("motion planner could not find path to approach position")
+	}
+	result.stepDone(ctx, s, "approach")
+
+	// Step 2: Descend to the target using a direct Cartesian move via the arm driver
+	down := s.armBaseDirection(ctx, up.Mul(-1))
+	currentPose, err := s.arm.EndPosition(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get arm position: %w", err)
+	}
+	s.logger.Infof("Descending %.0fmm to the target (direct Cartesian move)...", s.cfg.ApproachOffsetMm)
+	touchPose := spatialmath.NewPose(currentPose.Point().Add(down.Mul(s.cfg.ApproachOffsetMm)), currentPose.Orientation())
+	if err := s.arm.MoveToPosition(ctx, touchPose, nil); err != nil {
+		return nil, fmt.Errorf("failed to move to touch position: %w", err)
+	}
+	result.stepDone(ctx, s, "touch")
+
+	// Step 3: World-frame comparison
+	toolPose, err := s.motion.GetPose(ctx, result.ToolFrame, "world", nil, nil)
+	if err != nil {
+		s.logger.Warnf("Could not get tool world pose (non-fatal): %v", err)
+	} else {
+		result.measured = true
+		result.ToolPositionWorldFrame = toolPose.Pose().Point()
+		result.WorldFrameErrorMm = result.ToolPositionWorldFrame.Sub(result.TargetWorldFrame)
+		s.logger.Infof("World-frame error: (%.1f, %.1f, %.1f)mm, total: %.1fmm",
+			result.WorldFrameErrorMm.X, result.WorldFrameErrorMm.Y, result.WorldFrameErrorMm.Z,
+			vecNorm(result.WorldFrameErrorMm))
+	}
+
+	// Step 4: Retract back to the approach position
+	s.logger.Infof("Retracting %.0fmm (direct Cartesian move)...", s.cfg.ApproachOffsetMm)
+	if err := s.arm.MoveToPosition(ctx, currentPose, nil); err != nil {
+		s.logger.Warnf("Retract move failed (non-fatal): %v", err)
+	} else {
+		result.stepDone(ctx, s, "retract")
+	}
+
+	result.Verdict = s.cfg.Thresholds.judgeTouch(result)
+	result.Success = result.Verdict.Pass
+	if result.Success {
+		s.logger.Infof("RESULT: PASS - tool reached the target")
+	} else {
+		s.logger.Infof("RESULT: FAIL - %s", strings.Join(result.Verdict.Reasons, "; "))
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package handeyetest
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+)
+
+// verdict is the pass/fail outcome of a pick against the configured thresholds, with a
+// human-readable reason for every limit it broke.
+type verdict struct {
+	Pass    bool
+	Reasons []string
+}
+
+func (v verdict) toMap() map[string]interface{} {
+	reasons := v.Reasons
+	if reasons == nil {
+		reasons = []string{}
+	}
+	return map[string]interface{}{
+		"pass":    v.Pass,
+		"reasons": reasons,
+	}
+}
+
+// judgePick checks a pick against the thresholds. Holding the object is always required;
+// a limit whose measurement is missing fails, since it cannot be shown to hold.
+func (tc *ThresholdsConfig) judgePick(r *pickResult, graspDepthMm float64) verdict {
+	var reasons []string
+	if !r.IsHolding {
+		reasons = append(reasons, "gripper did not hold the object")
+	}
+
+	if tc.checksWorldOffset() {
+		if r.ObjectPositionWorldFrame == (r3.Vector{}) || r.GripperPositionWorldFrame == (r3.Vector{}) {
+			reasons = append(reasons, "world offset not measured")
+		} else {
+			// The gripper is meant to stop grasp_depth_offset_mm above the detected point,
+			// along the table normal.
+			up := r3.Vector{Z: 1}
+			if r.tableUp != (r3.Vector{}) {
+				up = r.tableUp
+			}
+			reasons = append(reasons, tc.worldOffsetReasons(r.WorldFrameOffsetMm.Sub(up.Mul(graspDepthMm)))...)
+		}
+	}
+
+	if tc.MaxApproachOffsetMm > 0 {
+		if r.redetected && !r.RedetectMatched {
+			reasons = append(reasons, "approach offset not measured: re-detection did not match the object")
+		} else if !r.approachMeasured {
+			reasons = append(reasons, "approach offset not measured")
+		} else if v := vecNorm(r.ApproachOffsetMm); v > tc.MaxApproachOffsetMm {
+			reasons = append(reasons, fmt.Sprintf("approach offset %.1fmm > %gmm limit", v, tc.MaxApproachOffsetMm))
+		}
+	}
+
+	if tc.MaxRedetectDriftMm > 0 && r.Place != nil {
+		if !r.Place.Redetected {
+			reasons = append(reasons, "placed object not re-detected")
+		} else if v := vecNorm(r.Place.PlaceOffsetMm); v > tc.MaxRedetectDriftMm {
+			reasons = append(reasons, fmt.Sprintf("re-detect drift %.1fmm > %gmm limit", v, tc.MaxRedetectDriftMm))
+		}
+	}
+
+	return verdict{Pass: len(reasons) == 0, Reasons: reasons}
+}
+
+// judgeTouch checks a touch's world-frame error against the world offset limits. The error
+// must have been measured, as holding the object is required of a pick.
+func (tc *ThresholdsConfig) judgeTouch(r *touchResult) verdict {
+	if !r.measured {
+		return verdict{Reasons: []string{"world offset not measured"}}
+	}
+	reasons := tc.worldOffsetReasons(r.WorldFrameErrorMm)
+	return verdict{Pass: len(reasons) == 0, Reasons: reasons}
+}
+
+func (tc *ThresholdsConfig) checksWorldOffset() bool {
+	return tc.MaxWorldOffsetMm > 0 || tc.MaxWorldOffsetXMm > 0 || tc.MaxWorldOffsetYMm > 0 || tc.MaxWorldOffsetZMm > 0
+}
+
+// worldOffsetReasons lists the world offset limits an offset breaks, in total and per axis.
+func (tc *ThresholdsConfig) worldOffsetReasons(offset r3.Vector) []string {
+	var reasons []string
+	if total := vecNorm(offset); tc.MaxWorldOffsetMm > 0 && total > tc.MaxWorldOffsetMm {
+		reasons = append(reasons, fmt.Sprintf("world offset %.1fmm > %gmm limit", total, tc.MaxWorldOffsetMm))
+	}
+	for _, al := range []struct {
+		axis  string
+		limit float64
+		value float64
+	}{
+		{"X", tc.MaxWorldOffsetXMm, offset.X},
+		{"Y", tc.MaxWorldOffsetYMm, offset.Y},
+		{"Z", tc.MaxWorldOffsetZMm, offset.Z},
+	} {
+		if v := math.Abs(al.value); al.limit > 0 && v > al.limit {
+			reasons = append(reasons, fmt.Sprintf("world offset %.1fmm > %gmm limit on %s", v, al.limit, al.axis))
+		}
+	}
+	return reasons
+}